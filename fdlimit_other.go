@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+// fdlimit_other.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+func openFileLimit() int64 {
+	return 0
+}
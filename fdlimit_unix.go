@@ -0,0 +1,18 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+// fdlimit_unix.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import "syscall"
+
+// openFileLimit -- the soft limit on open files, which every connection counts against, 0 if unknown
+func openFileLimit() int64 {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0
+	}
+	return int64(rl.Cur)
+}
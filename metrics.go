@@ -0,0 +1,188 @@
+// metrics.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricsFile -- set by -metricsfile to write the results there in OpenMetrics text format at the end of the run
+var metricsFile string
+
+// metricPhases -- the phase results logged so far, kept for -metricsfile
+var metricPhases []logMessage
+
+// recordMetrics -- keep a phase result for the metrics file
+func recordMetrics(l logEntry) {
+	if m, ok := l.(logMessage); ok && metricsFile != "" {
+		metricPhases = append(metricPhases, m)
+	}
+}
+
+// metricLabels -- the label set of a phase's samples
+func metricLabels(l logMessage) string {
+	labels := []string{"method", l.Method, "loop", strconv.Itoa(l.Loop)}
+	if l.Size != "" {
+		labels = append(labels, "size", l.Size)
+	}
+	if l.Endpoint != "" {
+		labels = append(labels, "endpoint", l.Endpoint)
+	}
+	if runLabel != "" {
+		labels = append(labels, "label", runLabel)
+	}
+	return formatLabels(labels...)
+}
+
+// formatLabels -- render name, value pairs as {name="value",...}, escaped for the text format
+func formatLabels(pairs ...string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var parts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+escape.Replace(pairs[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// writeMetrics -- write the phase results and run totals as gauges, replacing the file in one rename so a
+// textfile collector never reads half of it
+func writeMetrics(total summaryMessage) error {
+	var b bytes.Buffer
+	family := func(name, help string, sample func(l logMessage) (float64, bool)) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, l := range metricPhases {
+			if v, ok := sample(l); ok {
+				fmt.Fprintf(&b, "%s%s %g\n", name, metricLabels(l), v)
+			}
+		}
+	}
+	family("s3_benchmark_throughput_bytes_per_second", "Object bytes transferred per second by the phase.",
+		func(l logMessage) (float64, bool) { return float64(l.RawSpeed), l.Speed != "" })
+	family("s3_benchmark_operations_per_second", "Requests completed per second by the phase.",
+		func(l logMessage) (float64, bool) { return l.Operations, true })
+	family("s3_benchmark_errors", "Failed requests in the phase.",
+		func(l logMessage) (float64, bool) { return float64(l.Errors), true })
+	family("s3_benchmark_phase_duration_seconds", "How long the phase ran.",
+		func(l logMessage) (float64, bool) { return l.Time, true })
+
+	const latency = "s3_benchmark_latency_seconds"
+	fmt.Fprintf(&b, "# HELP %s Request latency percentiles of the phase.\n# TYPE %s gauge\n", latency, latency)
+	for _, l := range metricPhases {
+		labels := metricLabels(l)
+		for _, q := range []struct {
+			quantile string
+			ms       float64
+		}{{"0.5", l.LatencyP50}, {"0.9", l.LatencyP90}, {"0.99", l.LatencyP99}, {"0.999", l.LatencyP999},
+			{"0.9999", l.LatencyP9999}, {"1", l.LatencyMax}} {
+			fmt.Fprintf(&b, "%s%s,quantile=\"%s\"} %g\n", latency, strings.TrimSuffix(labels, "}"), q.quantile,
+				q.ms*float64(time.Millisecond)/float64(time.Second))
+		}
+	}
+
+	var runLabels string
+	if runLabel != "" {
+		runLabels = formatLabels("label", runLabel)
+	}
+	for _, m := range []struct {
+		name, help string
+		value      float64
+	}{
+		{"s3_benchmark_run_duration_seconds", "How long the whole run took.", total.Time},
+		{"s3_benchmark_run_objects", "Objects uploaded over the run.", float64(total.Objects)},
+		{"s3_benchmark_run_uploaded_bytes", "Bytes uploaded over the run.", float64(total.BytesUploaded)},
+		{"s3_benchmark_run_downloaded_bytes", "Bytes downloaded over the run.", float64(total.BytesDownloaded)},
+		{"s3_benchmark_run_errors", "Failed requests over the run.", float64(total.Errors)},
+	} {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s%s %g\n", m.name, m.help, m.name, m.name, runLabels, m.value)
+	}
+	b.WriteString("# EOF\n")
+
+	tmp := metricsFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, b.Bytes(), 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, metricsFile)
+}
+
+// metricsAddr -- set by -metricsaddr to serve live metrics on /metrics at this address during the run
+var metricsAddr string
+
+// inFlight -- the threads in the middle of a request
+var inFlight int64
+
+// livePhase -- the running phase and its rates over the last second, updated by the sampler
+var livePhase struct {
+	sync.Mutex
+	method             string
+	loop               int
+	opsRate, bytesRate float64
+}
+
+// setLivePhase -- note the phase now running, or none with an empty method
+func setLivePhase(loop int, method string) {
+	livePhase.Lock()
+	livePhase.method, livePhase.loop, livePhase.opsRate, livePhase.bytesRate = method, loop, 0, 0
+	livePhase.Unlock()
+}
+
+// setLiveRates -- record the running phase's operations and bytes per second over the last second
+func setLiveRates(ops, bytesRate float64) {
+	livePhase.Lock()
+	livePhase.opsRate, livePhase.bytesRate = ops, bytesRate
+	livePhase.Unlock()
+}
+
+// serveMetrics -- start the -metricsaddr server, failing at once if the address can't be used
+func serveMetrics() {
+	listener, err := net.Listen("tcp", metricsAddr)
+	if err != nil {
+		fatalf("Unable to listen on -metricsaddr %s: %v", metricsAddr, err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", liveMetrics)
+	go http.Serve(listener, mux)
+}
+
+// liveMetrics -- the counters of the running phase in Prometheus text format
+func liveMetrics(w http.ResponseWriter, r *http.Request) {
+	livePhase.Lock()
+	method, loop, opsRate, bytesRate := livePhase.method, livePhase.loop, livePhase.opsRate, livePhase.bytesRate
+	livePhase.Unlock()
+	labels := []string{"method", method, "loop", strconv.Itoa(loop)}
+	if runLabel != "" {
+		labels = append(labels, "label", runLabel)
+	}
+	phase := formatLabels(labels...)
+	if method == "" {
+		// Between phases, or setting up
+		phase = formatLabels(labels[4:]...)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range []struct {
+		name, help string
+		value      float64
+	}{
+		{"s3_benchmark_live_operations", "Requests completed so far in the running phase.", float64(atomic.LoadInt64(&opsDone))},
+		{"s3_benchmark_live_bytes", "Object bytes transferred so far in the running phase.", float64(atomic.LoadInt64(&bytesDone))},
+		{"s3_benchmark_live_errors", "Failed requests so far in the running phase.", float64(atomic.LoadInt64(&errorCount))},
+		{"s3_benchmark_live_requests_in_flight", "Requests in progress.", float64(atomic.LoadInt64(&inFlight))},
+		{"s3_benchmark_live_operations_rate", "Requests completed per second over the last second.", opsRate},
+		{"s3_benchmark_live_throughput_rate_bytes", "Object bytes transferred per second over the last second.", bytesRate},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s%s %g\n", m.name, m.help, m.name, m.name, phase, m.value)
+	}
+}
@@ -0,0 +1,308 @@
+// mixedops.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// The operations -ops can weight, in the order they are reported
+var mixedOpNames = []string{"put", "get", "head", "delete", "copy"}
+
+const (
+	opPut = iota
+	opGet
+	opHead
+	opDelete
+	opCopy
+)
+
+// mixedWeights -- set by -ops, the weight of each operation in the MIXED phase, nil for no MIXED phase
+var mixedWeights []float64
+var mixedWeightTotal float64
+
+// parseMixedOps -- parse op=weight pairs such as get=60,put=20,head=15,delete=5
+func parseMixedOps(arg string) error {
+	mixedWeights = make([]float64, len(mixedOpNames))
+	for _, entry := range strings.Split(arg, ",") {
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 {
+			return fmt.Errorf("%q is not op=weight", entry)
+		}
+		op := -1
+		for i, name := range mixedOpNames {
+			if strings.TrimSpace(parts[0]) == name {
+				op = i
+			}
+		}
+		if op < 0 {
+			return fmt.Errorf("unknown operation %q, expected put, get, head, delete or copy", parts[0])
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || weight < 0 {
+			return fmt.Errorf("%q is not a weight of 0 or more", parts[1])
+		}
+		mixedWeights[op] = weight
+		mixedWeightTotal += weight
+	}
+	if mixedWeightTotal == 0 {
+		return fmt.Errorf("no operation has a weight")
+	}
+	if mixedWeights[opDelete] > 0 && mixedWeights[opPut] == 0 && mixedWeights[opCopy] == 0 {
+		// Deletes only remove objects the phase wrote itself
+		return fmt.Errorf("delete needs put or copy to write the objects it deletes")
+	}
+	return nil
+}
+
+// pickMixedOp -- an operation chosen at random in proportion to the weights
+func pickMixedOp() int {
+	n := rand.Float64() * mixedWeightTotal
+	for op, weight := range mixedWeights {
+		if n < weight {
+			return op
+		}
+		n -= weight
+	}
+	// Rounding at the top end
+	return len(mixedWeights) - 1
+}
+
+// Objects the MIXED phase reads and copies from, the ones uploaded before it, which it never deletes
+var mixedBase int64
+
+// mixedWritten -- the objects the MIXED phase's PUTs and copies wrote and its DELETEs have not yet removed
+var mixedWritten []int64
+var mixedMu sync.Mutex
+
+// Requests of each operation in the phase, and their latencies
+var mixedCounts []int64
+var mixedLatencies []latencySet
+
+// takeWritten -- remove and return a random object the phase wrote, 0 if none is left
+func takeWritten() int64 {
+	mixedMu.Lock()
+	defer mixedMu.Unlock()
+	if len(mixedWritten) == 0 {
+		return 0
+	}
+	i := rand.Intn(len(mixedWritten))
+	objnum := mixedWritten[i]
+	mixedWritten[i] = mixedWritten[len(mixedWritten)-1]
+	mixedWritten = mixedWritten[:len(mixedWritten)-1]
+	return objnum
+}
+
+func addWritten(objnum int64) {
+	mixedMu.Lock()
+	mixedWritten = append(mixedWritten, objnum)
+	mixedMu.Unlock()
+}
+
+// runMixed -- one request of a MIXED thread, its operation picked by weight, false once the phase is over
+func runMixed(threadNum int) bool {
+	if !time.Now().Before(endtime) {
+		return false
+	}
+	op := pickMixedOp()
+	var objnum int64
+	if op == opDelete {
+		if objnum = takeWritten(); objnum == 0 {
+			// Nothing of the phase's own to delete yet
+			op = opPut
+			if mixedWeights[opPut] == 0 {
+				op = opCopy
+			}
+		}
+	}
+	var elapsed time.Duration
+	switch op {
+	case opPut, opCopy:
+		// New objects extend the loop's, so the DELETE phase removes them too
+		objnum = atomic.AddInt64(&uploadCount, 1)
+		var ok bool
+		if op == opPut {
+			elapsed, ok = uploadObject(objnum)
+		} else {
+			elapsed, ok = copyObject(rand.Int63n(mixedBase)+1, objnum)
+		}
+		if ok {
+			addWritten(objnum)
+		}
+	case opGet:
+		var status int
+		var n int64
+		elapsed, status, n, _ = downloadObject(rand.Int63n(mixedBase)+1, false)
+		if status == http.StatusOK {
+			atomic.AddInt64(&bytesDone, n)
+		} else if phaseCtx.Err() == nil {
+			atomic.AddInt64(&errorCount, 1)
+		}
+	case opHead:
+		elapsed = headObject(rand.Int63n(mixedBase) + 1)
+	case opDelete:
+		elapsed = deleteObject(objnum)
+	}
+	if phaseCtx.Err() != nil {
+		return false
+	}
+	atomic.AddInt64(&mixedCounts[op], 1)
+	mixedLatencies[op].record(threadNum, elapsed)
+	recordRequest(threadNum, elapsed)
+	return true
+}
+
+// headObject -- HEAD a single object, returning the request time
+func headObject(objnum int64) time.Duration {
+	if sdkClient != nil {
+		return sdkHeadObject(objnum)
+	}
+	prefix := objectURL(objnum, "")
+	req := newRequest(http.MethodHead, prefix, nil)
+	signRequest(req)
+	start := time.Now()
+	resp, err := doRequest(req)
+	if err != nil && phaseCtx.Err() != nil {
+		return time.Since(start)
+	} else if err != nil {
+		fatalf("FATAL: Error checking object %s: %v", prefix, err)
+	}
+	elapsed := time.Since(start)
+	if resp.StatusCode != http.StatusOK {
+		atomic.AddInt64(&errorCount, 1)
+		logRequestError("Head", prefix, resp)
+	}
+	drainBody(resp)
+	return elapsed
+}
+
+// copyObject -- copy object src to object dst on the server with a PUT and x-amz-copy-source, returning
+// the request time and whether it succeeded. No object data crosses the wire.
+func copyObject(src, dst int64) (time.Duration, bool) {
+	if sdkClient != nil {
+		return sdkCopyObject(src, dst)
+	}
+	prefix := objectURL(dst, "")
+	req := newRequest(http.MethodPut, prefix, nil)
+	// An x-amz header, so SigV2 signs it
+	req.Header.Set("X-Amz-Copy-Source", "/"+bucket+"/"+(&url.URL{Path: objectKey(src)}).EscapedPath())
+	signRequest(req)
+	start := time.Now()
+	resp, err := doRequest(req)
+	if err != nil && phaseCtx.Err() != nil {
+		return time.Since(start), false
+	} else if err != nil {
+		fatalf("FATAL: Error copying object to %s: %v", prefix, err)
+	}
+	elapsed := time.Since(start)
+	// A copy can also fail with an error in a 200 response body, but the timing is what matters here
+	ok := resp.StatusCode == http.StatusOK
+	if !ok {
+		atomic.AddInt64(&errorCount, 1)
+		logRequestError("Copy", prefix, resp)
+	}
+	drainBody(resp)
+	return elapsed, ok
+}
+
+// mixedOpStats -- the requests of one operation in the MIXED phase
+type mixedOpStats struct {
+	Op         string  `json:"op"`
+	Count      int64   `json:"count"`
+	Share      float64 `json:"share"`
+	Operations float64 `json:"totalOperations"`
+	LatencyP50 float64 `json:"latencyP50"`
+	LatencyP99 float64 `json:"latencyP99"`
+	LatencyMax float64 `json:"latencyMax"`
+}
+
+func (m mixedOpStats) String() string {
+	return fmt.Sprintf(", %s: %d (%.1f%%), %.1f operations/sec, latency p50/p99/max = %.1f/%.1f/%.1f ms",
+		m.Op, m.Count, m.Share, m.Operations, m.LatencyP50, m.LatencyP99, m.LatencyMax)
+}
+
+func runMixedPhase(loop int, total *summaryMessage) logMessage {
+	if noObjectsToRead(loop, "MIXED") {
+		return logMessage{LogTime: time.Now(), Loop: loop, Method: "MIXED", Size: sizeLabel, Endpoint: endpointLabel}
+	}
+	resetPhaseStats(threads)
+	mixedBase = downloadKeyspace()
+	mixedWritten = nil
+	mixedCounts = make([]int64, len(mixedOpNames))
+	mixedLatencies = make([]latencySet, len(mixedOpNames))
+	for op := range mixedLatencies {
+		mixedLatencies[op] = newLatencySet(threads)
+	}
+	starttime := time.Now()
+	endtime = starttime.Add(time.Second * time.Duration(durationSecs))
+	stopSampler := startSampler(loop, "MIXED")
+	stopContext := startPhaseContext(true)
+	// The counts per operation include any ramp, so there is none
+	startThreads(runMixed, 0)
+	waitThreads()
+	stopContext()
+	stopSampler()
+	mixedTime := time.Now().Sub(starttime).Seconds()
+	total.Errors += errorCount
+
+	bps := float64(bytesDone) / mixedTime
+	mixed := logMessage{
+		LogTime:     time.Now(),
+		Loop:        loop,
+		Method:      "MIXED",
+		Time:        mixedTime,
+		Objects:     opsDone,
+		Speed:       bytefmt.ByteSize(uint64(bps)),
+		RawSpeed:    uint64(bps),
+		Operations:  float64(opsDone) / mixedTime,
+		Utilization: utilization(mixedTime),
+		Redirects:   redirectCount,
+		Retries:     retryCount,
+		Goroutines:  peakGoroutines,
+		Errors:      errorCount,
+		Threads:     phaseThreads,
+		Size:        sizeLabel,
+		Endpoint:    endpointLabel,
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	for op, name := range mixedOpNames {
+		if mixedWeights[op] == 0 && mixedCounts[op] == 0 {
+			continue
+		}
+		all := mixedLatencies[op].sorted()
+		s := mixedOpStats{
+			Op:         name,
+			Count:      mixedCounts[op],
+			Operations: float64(mixedCounts[op]) / mixedTime,
+			LatencyP50: ms(percentile(all, 0.50)),
+			LatencyP99: ms(percentile(all, 0.99)),
+			LatencyMax: ms(percentile(all, 1)),
+		}
+		if opsDone > 0 {
+			s.Share = float64(mixedCounts[op]) / float64(opsDone) * 100
+		}
+		mixed.MixedOps = append(mixed.MixedOps, s)
+	}
+	setLatencies(&mixed, latencies)
+	setBreakdown(&mixed)
+	setSigning(&mixed)
+	setPerThread(&mixed)
+	setWire(&mixed)
+	setDialWait(&mixed)
+	setConnections(&mixed)
+	setAttempts(&mixed)
+	logit(mixed)
+	checkLatency(mixed)
+	return mixed
+}
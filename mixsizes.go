@@ -0,0 +1,164 @@
+// mixsizes.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// mixClass -- one size of -mixsizes, with the requests and bytes of that size in the running phase
+type mixClass struct {
+	label string
+	size  uint64
+	share float64
+	ops   int64
+	bytes int64
+}
+
+// mixClasses -- set by -mixsizes, the sizes upload threads are split between
+var mixClasses []*mixClass
+
+// The class of each object uploaded in the loop, by object number
+var mixObjects []uint8
+var mixMu sync.RWMutex
+
+// parseMixSizes -- parse size:percent pairs such as 4K:50,1M:50, the percentages adding up to 100
+func parseMixSizes(arg string) error {
+	var total float64
+	for _, entry := range strings.Split(arg, ",") {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("%q is not size:percent", entry)
+		}
+		size, err := parseSize(parts[0])
+		if err != nil || size == 0 {
+			return fmt.Errorf("%q is not a size greater than zero", parts[0])
+		}
+		share, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || share <= 0 {
+			return fmt.Errorf("%q is not a percentage greater than zero", parts[1])
+		}
+		total += share
+		mixClasses = append(mixClasses, &mixClass{label: strings.TrimSpace(parts[0]), size: size, share: share})
+	}
+	if len(mixClasses) > 255 {
+		return fmt.Errorf("at most 255 sizes")
+	}
+	if total < 99.99 || total > 100.01 {
+		return fmt.Errorf("the percentages add up to %v, not 100", total)
+	}
+	return nil
+}
+
+// mixMaxSize -- the largest -mixsizes size, which the upload data must hold
+func mixMaxSize() uint64 {
+	var max uint64
+	for _, c := range mixClasses {
+		if c.size > max {
+			max = c.size
+		}
+	}
+	return max
+}
+
+// mixThreadClass -- the size class of an upload thread: the threads are split between the sizes in order,
+// in proportion to their percentages
+func mixThreadClass(threadNum int) int {
+	position := (float64(threadNum) - 0.5) / float64(phaseThreads) * 100
+	var cumulative float64
+	for i, c := range mixClasses {
+		cumulative += c.share
+		if position < cumulative {
+			return i
+		}
+	}
+	return len(mixClasses) - 1
+}
+
+// setMixClass -- note the size class of an object as its upload starts
+func setMixClass(objnum int64, class int) {
+	mixMu.Lock()
+	for int64(len(mixObjects)) < objnum {
+		mixObjects = append(mixObjects, 0)
+	}
+	mixObjects[objnum-1] = uint8(class)
+	mixMu.Unlock()
+}
+
+// mixClassOf -- the size class of an uploaded object
+func mixClassOf(objnum int64) int {
+	mixMu.RLock()
+	defer mixMu.RUnlock()
+	if objnum < 1 || objnum > int64(len(mixObjects)) {
+		return 0
+	}
+	return int(mixObjects[objnum-1])
+}
+
+// mixRecord -- count a request of the object's size class, when -mixsizes is set
+func mixRecord(objnum int64, bytes int64) {
+	if mixClasses == nil {
+		return
+	}
+	c := mixClasses[mixClassOf(objnum)]
+	atomic.AddInt64(&c.ops, 1)
+	atomic.AddInt64(&c.bytes, bytes)
+}
+
+// resetMix -- clear the per-size counts at the start of a phase, or once it has ramped up
+func resetMix() {
+	for _, c := range mixClasses {
+		atomic.StoreInt64(&c.ops, 0)
+		atomic.StoreInt64(&c.bytes, 0)
+	}
+}
+
+// mixStats -- the results of one -mixsizes size within a phase
+type mixStats struct {
+	Size       string  `json:"size"`
+	Threads    int     `json:"threads,omitempty"`
+	Objects    int64   `json:"objects"`
+	Speed      string  `json:"avgSpeed"`
+	RawSpeed   uint64  `json:"rawSpeed"`
+	Operations float64 `json:"totalOperations"`
+}
+
+func (m mixStats) String() string {
+	msg := fmt.Sprintf(", %s: %sB/sec, %.1f operations/sec", m.Size, m.Speed, m.Operations)
+	if m.Threads > 0 {
+		msg += fmt.Sprintf(" on %d threads", m.Threads)
+	}
+	return msg
+}
+
+// setMix -- break a phase's throughput down by size, with the threads of each size for uploads
+func setMix(l *logMessage, uploads bool) {
+	if mixClasses == nil || l.Time <= 0 {
+		return
+	}
+	threads := make([]int, len(mixClasses))
+	if uploads {
+		for n := 1; n <= phaseThreads; n++ {
+			threads[mixThreadClass(n)]++
+		}
+	}
+	for i, c := range mixClasses {
+		bps := uint64(float64(atomic.LoadInt64(&c.bytes)) / l.Time)
+		ops := atomic.LoadInt64(&c.ops)
+		l.Mix = append(l.Mix, mixStats{
+			Size:       c.label,
+			Threads:    threads[i],
+			Objects:    ops,
+			Speed:      bytefmt.ByteSize(bps),
+			RawSpeed:   bps,
+			Operations: float64(ops) / l.Time,
+		})
+	}
+}
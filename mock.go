@@ -0,0 +1,435 @@
+// mock.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// mockServer -- a minimal in-memory S3 endpoint so the benchmark can run without a backend, checking the signature of
+// every signed request against -a and -s
+type mockServer struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+type mockListResult struct {
+	XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name        string   `xml:"Name"`
+	Prefix      string   `xml:"Prefix"`
+	Delimiter   string   `xml:"Delimiter,omitempty"`
+	Marker      string   `xml:"Marker"`
+	NextMarker  string   `xml:"NextMarker,omitempty"`
+	MaxKeys     int      `xml:"MaxKeys"`
+	IsTruncated bool     `xml:"IsTruncated"`
+	Contents    []struct {
+		Key  string `xml:"Key"`
+		Size int    `xml:"Size"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+type mockDelete struct {
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+// startMockServer -- start an in-process S3 mock and return its URL
+func startMockServer() string {
+	m := &mockServer{buckets: make(map[string]map[string][]byte)}
+	return httptest.NewServer(m).URL
+}
+
+func (m *mockServer) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (m *mockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if status, code := m.authorize(r); status != 0 {
+		m.error(w, status, code)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucketName, key := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		bucketName, key = path[:i], path[i+1:]
+	}
+	if bucketName == "" {
+		m.error(w, http.StatusBadRequest, "InvalidBucketName")
+		return
+	}
+	if key == "" {
+		m.serveBucket(w, r, bucketName)
+		return
+	}
+	m.serveObject(w, r, bucketName, key)
+}
+
+func (m *mockServer) serveBucket(w http.ResponseWriter, r *http.Request, bucketName string) {
+	switch r.Method {
+	case http.MethodPut:
+		m.mu.Lock()
+		if _, ok := m.buckets[bucketName]; !ok {
+			m.buckets[bucketName] = make(map[string][]byte)
+		}
+		m.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	case http.MethodHead:
+		m.mu.RLock()
+		_, ok := m.buckets[bucketName]
+		m.mu.RUnlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		if _, ok := r.URL.Query()["versions"]; ok {
+			// The mock keeps no versions
+			m.error(w, http.StatusNotImplemented, "NotImplemented")
+			return
+		}
+		m.list(w, r, bucketName)
+	case http.MethodPost:
+		if _, ok := r.URL.Query()["delete"]; !ok {
+			m.error(w, http.StatusNotImplemented, "NotImplemented")
+			return
+		}
+		var del mockDelete
+		if err := xml.NewDecoder(r.Body).Decode(&del); err != nil {
+			m.error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		m.mu.Lock()
+		for _, obj := range del.Objects {
+			delete(m.buckets[bucketName], obj.Key)
+		}
+		m.mu.Unlock()
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<DeleteResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></DeleteResult>`)
+	default:
+		m.error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (m *mockServer) list(w http.ResponseWriter, r *http.Request, bucketName string) {
+	query := r.URL.Query()
+	result := mockListResult{
+		Name:      bucketName,
+		Prefix:    query.Get("prefix"),
+		Delimiter: query.Get("delimiter"),
+		Marker:    query.Get("marker"),
+		MaxKeys:   1000,
+	}
+	if maxKeys, err := strconv.Atoi(query.Get("max-keys")); err == nil && maxKeys > 0 && maxKeys < 1000 {
+		result.MaxKeys = maxKeys
+	}
+	m.mu.RLock()
+	objects, ok := m.buckets[bucketName]
+	var keys []string
+	for key := range objects {
+		if strings.HasPrefix(key, result.Prefix) && key > result.Marker {
+			keys = append(keys, key)
+		}
+	}
+	sizes := make(map[string]int, len(keys))
+	for _, key := range keys {
+		sizes[key] = len(objects[key])
+	}
+	m.mu.RUnlock()
+	if !ok {
+		m.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	sort.Strings(keys)
+	var listed int
+	for _, key := range keys {
+		if listed == result.MaxKeys {
+			result.IsTruncated = true
+			break
+		}
+		if result.Delimiter != "" {
+			// Keys with the delimiter after the prefix roll up into one common prefix each
+			if i := strings.Index(key[len(result.Prefix):], result.Delimiter); i >= 0 {
+				prefix := key[:len(result.Prefix)+i+len(result.Delimiter)]
+				if n := len(result.CommonPrefixes); n == 0 || result.CommonPrefixes[n-1].Prefix != prefix {
+					result.CommonPrefixes = append(result.CommonPrefixes, struct {
+						Prefix string `xml:"Prefix"`
+					}{prefix})
+					result.NextMarker = prefix
+					listed++
+				}
+				continue
+			}
+		}
+		result.Contents = append(result.Contents, struct {
+			Key  string `xml:"Key"`
+			Size int    `xml:"Size"`
+		}{key, sizes[key]})
+		result.NextMarker = key
+		listed++
+	}
+	if !result.IsTruncated {
+		result.NextMarker = ""
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(&result)
+}
+
+func (m *mockServer) serveObject(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	m.mu.RLock()
+	objects, ok := m.buckets[bucketName]
+	data, exists := objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		m.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			m.copyObject(w, bucketName, key, source)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			m.error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		sum := md5.Sum(body)
+		m.mu.Lock()
+		m.buckets[bucketName][key] = body
+		m.mu.Unlock()
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		if !exists {
+			m.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		m.mu.Lock()
+		delete(m.buckets[bucketName], key)
+		m.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		m.error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// copyObject -- serve a PUT with x-amz-copy-source, copying /bucket/key within the mock
+func (m *mockServer) copyObject(w http.ResponseWriter, bucketName, key, source string) {
+	if unescaped, err := url.PathUnescape(source); err == nil {
+		source = unescaped
+	}
+	parts := strings.SplitN(strings.TrimPrefix(source, "/"), "/", 2)
+	if len(parts) != 2 {
+		m.error(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	m.mu.Lock()
+	data, exists := m.buckets[parts[0]][parts[1]]
+	if exists {
+		m.buckets[bucketName][key] = data
+	}
+	m.mu.Unlock()
+	if !exists {
+		m.error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	sum := md5.Sum(data)
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprintf(w, `<CopyObjectResult><ETag>"%s"</ETag></CopyObjectResult>`, hex.EncodeToString(sum[:]))
+}
+
+// mockSubresources -- the query parameters S3 includes in the canonical resource of a SigV2 string to sign
+var mockSubresources = map[string]bool{
+	"acl": true, "cors": true, "delete": true, "lifecycle": true, "location": true, "logging": true,
+	"notification": true, "partNumber": true, "policy": true, "requestPayment": true, "tagging": true,
+	"torrent": true, "uploadId": true, "uploads": true, "versionId": true, "versioning": true, "versions": true,
+	"website": true, "response-cache-control": true, "response-content-disposition": true,
+	"response-content-encoding": true, "response-content-language": true, "response-content-type": true,
+	"response-expires": true,
+}
+
+// authorize -- check a signed request against the benchmark's keys, returning the status and error code to refuse
+// it with, or 0 to serve it; unsigned requests are served as anonymous ones
+func (m *mockServer) authorize(r *http.Request) (int, string) {
+	auth := r.Header.Get("Authorization")
+	switch {
+	case auth == "":
+		return 0, ""
+	case strings.HasPrefix(auth, "AWS "):
+		return m.authorizeV2(r, strings.TrimPrefix(auth, "AWS "))
+	case strings.HasPrefix(auth, "AWS4-HMAC-SHA256 "):
+		return m.authorizeV4(r, strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "))
+	}
+	return http.StatusBadRequest, "InvalidArgument"
+}
+
+// authorizeV2 -- check an "AWS key:signature" Authorization header
+func (m *mockServer) authorizeV2(r *http.Request, credential string) (int, string) {
+	i := strings.LastIndex(credential, ":")
+	if i < 0 {
+		return http.StatusBadRequest, "InvalidArgument"
+	}
+	if credential[:i] != accessKey {
+		return http.StatusForbidden, "InvalidAccessKeyId"
+	}
+	if r.Header.Get("Date") == "" && r.Header.Get("X-Amz-Date") == "" {
+		return http.StatusForbidden, "AccessDenied"
+	}
+	var names []string
+	for name := range r.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-amz-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	toSign := r.Method + "\n" + r.Header.Get("Content-MD5") + "\n" + r.Header.Get("Content-Type") + "\n" +
+		r.Header.Get("Date") + "\n"
+	for _, name := range names {
+		toSign += name + ":" + strings.Join(r.Header[http.CanonicalHeaderKey(name)], ",") + "\n"
+	}
+	query := r.URL.Query()
+	var subresources []string
+	for name := range query {
+		if mockSubresources[name] {
+			subresources = append(subresources, name)
+		}
+	}
+	sort.Strings(subresources)
+	for i, name := range subresources {
+		if value := query.Get(name); value != "" {
+			subresources[i] = name + "=" + value
+		}
+	}
+	toSign += r.URL.EscapedPath()
+	if len(subresources) > 0 {
+		toSign += "?" + strings.Join(subresources, "&")
+	}
+	mac := hmac.New(sha1.New, []byte(secretKey))
+	mac.Write([]byte(toSign))
+	if !hmac.Equal([]byte(credential[i+1:]), []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))) {
+		return http.StatusForbidden, "SignatureDoesNotMatch"
+	}
+	return 0, ""
+}
+
+// authorizeV4 -- check an AWS4-HMAC-SHA256 Authorization header, over the headers it says were signed
+func (m *mockServer) authorizeV4(r *http.Request, params string) (int, string) {
+	fields := make(map[string]string)
+	for _, field := range strings.Split(params, ",") {
+		if kv := strings.SplitN(strings.TrimSpace(field), "=", 2); len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	scope := strings.Split(fields["Credential"], "/")
+	date := r.Header.Get("X-Amz-Date")
+	if len(scope) != 5 || scope[4] != "aws4_request" || fields["SignedHeaders"] == "" || fields["Signature"] == "" ||
+		!strings.HasPrefix(date, scope[1]) {
+		return http.StatusBadRequest, "AuthorizationHeaderMalformed"
+	}
+	if scope[0] != accessKey {
+		return http.StatusForbidden, "InvalidAccessKeyId"
+	}
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+	if payload == "" {
+		// Without the header the body itself is signed
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return http.StatusBadRequest, "IncompleteBody"
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		payload = hex.EncodeToString(sum[:])
+	}
+	var headers string
+	for _, name := range strings.Split(fields["SignedHeaders"], ";") {
+		var values []string
+		switch name {
+		case "host":
+			values = []string{r.Host}
+		case "content-length":
+			values = []string{strconv.FormatInt(r.ContentLength, 10)}
+		default:
+			for _, value := range r.Header[http.CanonicalHeaderKey(name)] {
+				values = append(values, strings.Join(strings.Fields(value), " "))
+			}
+		}
+		headers += name + ":" + strings.Join(values, ",") + "\n"
+	}
+	canonical := r.Method + "\n" + r.URL.EscapedPath() + "\n" + mockV4Query(r.URL.Query()) + "\n" + headers + "\n" +
+		fields["SignedHeaders"] + "\n" + payload
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + date + "\n" + strings.Join(scope[1:], "/") + "\n" + hex.EncodeToString(hash[:])
+	key := []byte("AWS4" + secretKey)
+	for _, part := range append(scope[1:], toSign) {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if !hmac.Equal([]byte(fields["Signature"]), []byte(hex.EncodeToString(key))) {
+		return http.StatusForbidden, "SignatureDoesNotMatch"
+	}
+	return 0, ""
+}
+
+// mockV4Query -- the SigV4 canonical query string, sorted by name and then value
+func mockV4Query(query url.Values) string {
+	var names []string
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return mockV4Escape(names[i]) < mockV4Escape(names[j]) })
+	var params []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, mockV4Escape(name)+"="+mockV4Escape(value))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// mockV4Escape -- percent-encode everything but the characters SigV4 leaves unreserved
+func mockV4Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
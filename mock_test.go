@@ -0,0 +1,82 @@
+// mock_test.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMockSignatureV4 -- the mock accepts the get-vanilla case of the AWS SigV4 test suite and refuses it tampered with
+func TestMockSignatureV4(t *testing.T) {
+	accessKey, secretKey = "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	m := &mockServer{buckets: make(map[string]map[string][]byte)}
+	auth := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	for _, c := range []struct {
+		name, path, auth string
+		status           int
+		code             string
+	}{
+		{"vanilla", "/", auth, 0, ""},
+		{"other path", "/other", auth, http.StatusForbidden, "SignatureDoesNotMatch"},
+		{"other key", "/", "AWS4-HMAC-SHA256 Credential=AKIDOTHER/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, Signature=5fa00fa3", http.StatusForbidden, "InvalidAccessKeyId"},
+		{"malformed", "/", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE", http.StatusBadRequest, "AuthorizationHeaderMalformed"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://example.amazonaws.com"+c.path, nil)
+		r.Header.Set("X-Amz-Date", "20150830T123600Z")
+		r.Header.Set("Authorization", c.auth)
+		if status, code := m.authorize(r); status != c.status || code != c.code {
+			t.Errorf("%s: authorize = %d %q, want %d %q", c.name, status, code, c.status, c.code)
+		}
+	}
+}
+
+// TestMockSignatureV2 -- the mock serves requests signed by setSignature and unsigned ones, but not a bad signature
+func TestMockSignatureV2(t *testing.T) {
+	accessKey, secretKey = "AKIAEXAMPLE", "secret"
+	endpoint := startMockServer()
+	send := func(method, path string, sign func(*http.Request)) int {
+		req, _ := http.NewRequest(method, endpoint+path, nil)
+		req.URL.RawQuery = "versionId=null"
+		sign(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := send(http.MethodPut, "/sig-test", setSignature); status != http.StatusOK {
+		t.Errorf("signed PUT: status %d, want 200", status)
+	}
+	if status := send(http.MethodHead, "/sig-test", func(*http.Request) {}); status != http.StatusOK {
+		t.Errorf("unsigned HEAD: status %d, want 200", status)
+	}
+	status := send(http.MethodHead, "/sig-test", func(req *http.Request) {
+		req.URL.RawQuery = "response-content-type=text%2Fplain&versionId=null"
+		setSignature(req)
+	})
+	if status != http.StatusOK {
+		t.Errorf("HEAD with a response override: status %d, want 200", status)
+	}
+	status = send(http.MethodHead, "/sig-test", func(req *http.Request) {
+		setSignature(req)
+		// A subresource added after signing is not covered by the signature
+		req.URL.RawQuery = "versionId=1"
+	})
+	if status != http.StatusForbidden {
+		t.Errorf("HEAD with a changed subresource: status %d, want 403", status)
+	}
+	status = send(http.MethodHead, "/sig-test", func(req *http.Request) {
+		secretKey = "wrong"
+		setSignature(req)
+		secretKey = "secret"
+	})
+	if status != http.StatusForbidden {
+		t.Errorf("HEAD signed with the wrong key: status %d, want 403", status)
+	}
+}
@@ -6,63 +6,229 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/hmac"
+	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"math/rand"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"os"
+	"os/signal"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
 
 	"code.cloudfoundry.org/bytefmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/signer/v4"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
+// Set at build time, e.g. -ldflags "-X main.version=v3.2 -X main.gitCommit=$(git rev-parse --short HEAD)"
+var version = "v3.1"
+var gitCommit string
+
 // Global variables
-var accessKey, secretKey, urlHost, bucket string
-var durationSecs, threads, loops int
+var accessKey, secretKey, urlHost, region, bucket string
+var durationSecs, threads, loops, rampupSecs, keyDepth, calibrateSecs, objectVersions, partNumber int
+var getThreads, deleteThreads, phaseThreads int
 var objectSize uint64
+var sizeJitter float64
+var maxObjects, objectCount, readSet, resumeCount int64
+var minThroughput, fillTo uint64
+var maxP99 float64
 var objectData []byte
-var uploadCount, downloadCount, deleteCount int64
+var uploadCount, downloadCount, deleteCount, attributesCount int64
+var requestNanos, opsDone, bytesDone, redirectCount, retryCount, errorCount int64
+var missingCount, staleCount, readBytes int64
+var latencies, readLatencies latencySet
 var endtime, uploadFinish, downloadFinish, deleteFinish time.Time
-var jsonPrint bool
+var jsonPrint, ndjson, streamData, expect100, compressLog, chunkedUpload, showCompression, noDrain bool
+var readAfterWrite, verifyData, objectAttributes, verifyDelete, overwrite bool
+var objectACL, objectSuffix, cacheControl, contentDisposition string
+
+// Object Lock retention set on uploads by -lockmode and -lockuntil
+var lockMode string
+var lockUntil time.Time
+var hostHeader string
+var sdkRetries int
+var sizeLabel, endpointLabel, runLabel string
 var wg sync.WaitGroup
 
 type logMessage struct {
-	LogTime    time.Time `json:"time"`
-	Method     string    `json:"method"`
-	Loop       int       `json:"loop"`
-	Time       float64   `json:"timeTaken"`
-	Objects    int64     `json:"totalObjects"`
-	Speed      string    `json:"avgSpeed"`
-	RawSpeed   uint64    `json:"rawSpeed"`
-	Operations float64   `json:"totalOperations"`
+	LogTime      time.Time      `json:"time"`
+	Method       string         `json:"method"`
+	Loop         int            `json:"loop"`
+	Time         float64        `json:"timeTaken"`
+	Objects      int64          `json:"totalObjects"`
+	Speed        string         `json:"avgSpeed"`
+	RawSpeed     uint64         `json:"rawSpeed"`
+	Operations   float64        `json:"totalOperations"`
+	Utilization  float64        `json:"utilization"`
+	PerThread    float64        `json:"operationsPerThread,omitempty"`
+	Redirects    int64          `json:"redirects,omitempty"`
+	Retries      int64          `json:"connRetries,omitempty"`
+	Goroutines   int            `json:"peakGoroutines,omitempty"`
+	Errors       int64          `json:"errors"`
+	Threads      int            `json:"threads"`
+	Size         string         `json:"size,omitempty"`
+	Endpoint     string         `json:"endpoint,omitempty"`
+	Missing      int64          `json:"missing,omitempty"`
+	Stale        int64          `json:"stale,omitempty"`
+	StaleShare   float64        `json:"staleShare,omitempty"`
+	Convergence  *float64       `json:"convergenceMs,omitempty"`
+	ReadSet      int64          `json:"readSet,omitempty"`
+	Sequential   bool           `json:"sequential,omitempty"`
+	Resumed      int64          `json:"resumedAfter,omitempty"`
+	Filled       uint64         `json:"filled,omitempty"`
+	WorkingSet   int64          `json:"workingSet,omitempty"`
+	Overwrites   int64          `json:"overwrites,omitempty"`
+	Listed       *int64         `json:"listedObjects,omitempty"`
+	Breakdown    *breakdown     `json:"breakdown,omitempty"`
+	Signing      *signingStats  `json:"signing,omitempty"`
+	Wire         *wireStats     `json:"wire,omitempty"`
+	DialWait     *dialWaitStats `json:"dialWait,omitempty"`
+	Connections  *connStats     `json:"connections,omitempty"`
+	Attempts     *attemptStats  `json:"attempts,omitempty"`
+	List         *listStats     `json:"list,omitempty"`
+	MixedOps     []mixedOpStats `json:"mixedOps,omitempty"`
+	Mix          []mixStats     `json:"mix,omitempty"`
+	Trimmed      *trimmedStats  `json:"trimmed,omitempty"`
+	StoppedBy    string         `json:"stoppedBy,omitempty"`
+	LatencyP50   float64        `json:"latencyP50"`
+	LatencyP90   float64        `json:"latencyP90"`
+	LatencyP99   float64        `json:"latencyP99"`
+	LatencyP999  float64        `json:"latencyP999"`
+	LatencyP9999 float64        `json:"latencyP9999"`
+	TailWarning  string         `json:"tailWarning,omitempty"`
+	LatencyMax   float64        `json:"latencyMax"`
 }
 
 func (l logMessage) String() string {
+	var msg string
 	if l.Speed != "" {
-		return fmt.Sprintf("%s Loop %d: %s time %.1f secs, objects = %d, speed = %sB/sec, %.1f operations/sec.",
-			l.LogTime.Format(http.TimeFormat), l.Loop, l.Method, l.Time, l.Objects, l.Speed, l.Operations)
+		msg = fmt.Sprintf("%s Loop %d: %s time %.1f secs, objects = %d, speed = %sB/sec, %.1f operations/sec, %.1f%% utilization",
+			l.LogTime.Format(http.TimeFormat), l.Loop, l.Method, l.Time, l.Objects, l.Speed, l.Operations, l.Utilization)
+	} else {
+		msg = fmt.Sprintf("%s Loop %d: %s time %.1f secs, %.1f operations/sec, %.1f%% utilization",
+			l.LogTime.Format(http.TimeFormat), l.Loop, l.Method, l.Time, l.Operations, l.Utilization)
+	}
+	if l.PerThread > 0 {
+		msg += fmt.Sprintf(", %.1f operations/sec per thread", l.PerThread)
+	}
+	msg += fmt.Sprintf(", latency p50/p90/p99/max = %.1f/%.1f/%.1f/%.1f ms",
+		l.LatencyP50, l.LatencyP90, l.LatencyP99, l.LatencyMax)
+	msg += fmt.Sprintf(", p99.9/p99.99 = %.1f/%.1f ms", l.LatencyP999, l.LatencyP9999)
+	if l.TailWarning != "" {
+		msg += " (" + l.TailWarning + ")"
+	}
+	if l.Trimmed != nil {
+		msg += l.Trimmed.String()
+	}
+	if l.Redirects > 0 {
+		msg += fmt.Sprintf(", redirects = %d", l.Redirects)
+	}
+	if l.Retries > 0 {
+		msg += fmt.Sprintf(", connection retries = %d", l.Retries)
+	}
+	if l.Goroutines > 0 {
+		msg += fmt.Sprintf(", peak goroutines = %d", l.Goroutines)
+	}
+	if l.Errors > 0 {
+		msg += fmt.Sprintf(", errors = %d", l.Errors)
+	}
+	if l.Missing > 0 {
+		msg += fmt.Sprintf(", missing = %d", l.Missing)
+	}
+	if l.Stale > 0 {
+		msg += fmt.Sprintf(", stale = %d", l.Stale)
+	}
+	if l.StaleShare > 0 {
+		msg += fmt.Sprintf(", %.2f%% of reads stale", l.StaleShare)
+	}
+	if l.Convergence != nil {
+		msg += fmt.Sprintf(", new data visible after %.1f ms on average", *l.Convergence)
+	}
+	if l.Resumed > 0 {
+		msg += fmt.Sprintf(", resumed after object %d", l.Resumed)
+	}
+	if l.Filled > 0 {
+		msg += fmt.Sprintf(", bucket filled to %s in %d objects", bytefmt.ByteSize(l.Filled), l.Objects+l.Resumed)
+	}
+	if l.ReadSet > 0 {
+		msg += fmt.Sprintf(", read set = %d objects", l.ReadSet)
+	}
+	if l.Sequential {
+		msg += ", read in order"
+	}
+	if l.WorkingSet > 0 {
+		msg += fmt.Sprintf(", working set = %d objects, overwrites = %d", l.WorkingSet, l.Overwrites)
+	}
+	if l.Breakdown != nil {
+		msg += l.Breakdown.String()
+	}
+	if l.Signing != nil {
+		msg += l.Signing.String()
+	}
+	if l.Wire != nil {
+		msg += l.Wire.String()
+	}
+	if l.DialWait != nil {
+		msg += l.DialWait.String()
+	}
+	if l.Connections != nil {
+		msg += l.Connections.String()
+	}
+	if l.List != nil {
+		msg += l.List.String()
+	}
+	for _, m := range l.MixedOps {
+		msg += m.String()
+	}
+	if l.Attempts != nil && l.Attempts.Attempts > l.Attempts.Successes {
+		msg += l.Attempts.String()
+	}
+	for _, m := range l.Mix {
+		msg += m.String()
+	}
+	if l.Size != "" {
+		msg += ", size = " + l.Size
+	}
+	if l.Endpoint != "" {
+		msg += ", endpoint = " + l.Endpoint
+	}
+	if l.StoppedBy != "" {
+		msg += ", stopped by " + l.StoppedBy
 	}
-	return fmt.Sprintf("%s Loop %d: %s time %.1f secs, %.1f operations/sec.",
-		l.LogTime.Format(http.TimeFormat), l.Loop, l.Method, l.Time, l.Operations)
+	return msg + "."
 }
 
 func (l logMessage) JSON() string {
@@ -73,386 +239,4271 @@ func (l logMessage) JSON() string {
 	return string(data)
 }
 
-var logfile *os.File
+// summaryMessage -- grand totals across every loop and phase of the run
+type summaryMessage struct {
+	LogTime         time.Time `json:"time"`
+	Time            float64   `json:"timeTaken"`
+	Objects         int64     `json:"totalObjects"`
+	BytesUploaded   uint64    `json:"bytesUploaded"`
+	BytesDownloaded uint64    `json:"bytesDownloaded"`
+	Errors          int64     `json:"errors"`
+}
 
-func init() {
-	logfile, _ = os.OpenFile("benchmark.log", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+func (s summaryMessage) String() string {
+	return fmt.Sprintf("%s Total: time %.1f secs, objects = %d, uploaded = %sB, downloaded = %sB, errors = %d.",
+		s.LogTime.Format(http.TimeFormat), s.Time, s.Objects, bytefmt.ByteSize(s.BytesUploaded),
+		bytefmt.ByteSize(s.BytesDownloaded), s.Errors)
 }
 
-func logit(l logMessage) {
-	var msg string
-	if jsonPrint {
-		msg = l.JSON()
-	} else {
-		msg = l.String()
-	}
-	fmt.Println(msg)
-	if logfile != nil {
-		logfile.WriteString(msg + "\n")
+func (s summaryMessage) JSON() string {
+	data, err := json.Marshal(&s)
+	if err != nil {
+		panic(err)
 	}
+	return string(data)
 }
 
-// HTTPTransport - Our HTTP transport used for the roundtripper below
-var HTTPTransport http.RoundTripper = &http.Transport{
-	Proxy: http.ProxyFromEnvironment,
-	Dial: (&net.Dialer{
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-	}).Dial,
-	TLSHandshakeTimeout:   10 * time.Second,
-	ExpectContinueTimeout: 0,
-	// Allow an unlimited number of idle connections
-	MaxIdleConnsPerHost: 4096,
-	MaxIdleConns:        0,
-	// But limit their idle time
-	IdleConnTimeout: time.Minute,
-	// Ignore TLS errors
-	TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+// sweepMessage -- one row of the -zsweep table, averaged over all loops at that size,
+// or over the cold first loop or the steady later ones when Period is set
+type sweepMessage struct {
+	Size      string  `json:"size"`
+	Period    string  `json:"period,omitempty"`
+	PutSpeed  uint64  `json:"putSpeed"`
+	PutOps    float64 `json:"putOperations"`
+	GetSpeed  uint64  `json:"getSpeed"`
+	GetOps    float64 `json:"getOperations"`
+	DeleteOps float64 `json:"deleteOperations"`
 }
 
-var httpClient = &http.Client{Transport: HTTPTransport}
+const sweepHeader = "Size           PUT B/sec      PUT ops/sec    GET B/sec      GET ops/sec    DELETE ops/sec"
 
-func getS3Client() *s3.S3 {
-	// Build our config
-	creds := credentials.NewStaticCredentials(accessKey, secretKey, "")
-	loglevel := aws.LogOff
-	// Build the rest of the configuration
-	awsConfig := &aws.Config{
-		Region:               aws.String("us-east-1"),
-		Endpoint:             aws.String(urlHost),
-		Credentials:          creds,
-		LogLevel:             &loglevel,
-		S3ForcePathStyle:     aws.Bool(true),
-		S3Disable100Continue: aws.Bool(true),
-		// Comment following to use default transport
-		HTTPClient: &http.Client{Transport: HTTPTransport},
+func (s sweepMessage) String() string {
+	name := s.Size
+	if s.Period != "" {
+		name += " " + s.Period
 	}
-	session := session.New(awsConfig)
-	client := s3.New(session)
-	if client == nil {
-		log.Fatalf("FATAL: Unable to create new client.")
+	return fmt.Sprintf("%-14s %-14s %-14.1f %-14s %-14.1f %.1f", name, bytefmt.ByteSize(s.PutSpeed), s.PutOps,
+		bytefmt.ByteSize(s.GetSpeed), s.GetOps, s.DeleteOps)
+}
+
+// add -- add one loop's results, weighted for a mean over count loops
+func (s *sweepMessage) add(put, get, del logMessage, count int) {
+	s.PutSpeed += put.RawSpeed / uint64(count)
+	s.PutOps += put.Operations / float64(count)
+	s.GetSpeed += get.RawSpeed / uint64(count)
+	s.GetOps += get.Operations / float64(count)
+	s.DeleteOps += del.Operations / float64(count)
+}
+
+func (s sweepMessage) JSON() string {
+	data, err := json.Marshal(&s)
+	if err != nil {
+		panic(err)
 	}
-	// Return success
-	return client
+	return string(data)
 }
 
-func createBucket() {
-	// Get a client
-	client := getS3Client()
-	// Create our bucket (may already exist without error)
-	in := &s3.CreateBucketInput{Bucket: aws.String(bucket)}
-	if _, err := client.CreateBucket(in); err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
-			switch awsErr.Code() {
-			case "BucketAlreadyOwnedByYou":
-				fallthrough
-			case "BucketAlreadyExists":
-				return
-			}
-		}
-		log.Fatalf("FATAL: Unable to create bucket %s (is your access and secret correct?): %v", bucket, err)
+// endpointMessage -- one row of the endpoint comparison table, averaged over all loops and sizes
+type endpointMessage struct {
+	Endpoint string `json:"endpoint"`
+	Region   string `json:"region"`
+	sweepMessage
+}
+
+const endpointHeader = "Region         PUT B/sec      PUT ops/sec    GET B/sec      GET ops/sec    DELETE ops/sec Endpoint"
+
+func (e endpointMessage) String() string {
+	return fmt.Sprintf("%-14s %-14s %-14.1f %-14s %-14.1f %-14.1f %s", e.Region, bytefmt.ByteSize(e.PutSpeed), e.PutOps,
+		bytefmt.ByteSize(e.GetSpeed), e.GetOps, e.DeleteOps, e.Endpoint)
+}
+
+func (e endpointMessage) JSON() string {
+	data, err := json.Marshal(&e)
+	if err != nil {
+		panic(err)
 	}
+	return string(data)
 }
 
-func deleteAllObjects() {
-	// Get a client
-	client := getS3Client()
-	// Use multiple routines to do the actual delete
-	var doneDeletes sync.WaitGroup
-	// Loop deleting reading as big a list as we can
-	var keyMarker *string
-	var err error
-	for loop := 1; ; loop++ {
-		// Delete all the existing objects and versions in the bucket
-		in := &s3.ListObjectsInput{Bucket: aws.String(bucket), Marker: keyMarker, MaxKeys: aws.Int64(1000)}
-		if listObjects, listErr := client.ListObjects(in); listErr == nil {
-			delete := &s3.Delete{Quiet: aws.Bool(true)}
-			for _, version := range listObjects.Contents {
-				delete.Objects = append(delete.Objects, &s3.ObjectIdentifier{Key: version.Key})
-			}
-			if len(delete.Objects) > 0 {
-				// Start a delete routine
-				doDelete := func(bucket string, delete *s3.Delete) {
-					if _, e := client.DeleteObjects(
-						&s3.DeleteObjectsInput{
-							Bucket: aws.String(bucket),
-							Delete: delete,
-						}); e != nil {
-						err = fmt.Errorf("DeleteObjects unexpected failure: %s", e.Error())
-					}
-					doneDeletes.Done()
-				}
-				doneDeletes.Add(1)
-				go doDelete(bucket, delete)
-			}
-			// Advance to next versions
-			if listObjects.IsTruncated == nil || !*listObjects.IsTruncated {
-				break
-			}
-			keyMarker = listObjects.NextMarker
-		} else {
-			// The bucket may not exist, just ignore in that case
-			if strings.HasPrefix(listErr.Error(), "NoSuchBucket") {
-				return
-			}
-			err = fmt.Errorf("ListObjectVersions unexpected failure: %v", listErr)
-			break
-		}
+// headerMessage -- the effective configuration of a run, so archived results say how they were produced
+type headerMessage struct {
+	Version   string            `json:"version"`
+	Commit    string            `json:"commit,omitempty"`
+	GoVersion string            `json:"goVersion"`
+	Hostname  string            `json:"hostname"`
+	Start     time.Time         `json:"start"`
+	Flags     map[string]string `json:"flags"`
+}
+
+// newHeaderMessage -- describe this run, with every flag's value but the secret key
+func newHeaderMessage(flags *flag.FlagSet) headerMessage {
+	h := headerMessage{Version: version, Commit: gitCommit, GoVersion: runtime.Version(), Start: time.Now(),
+		Flags: make(map[string]string)}
+	h.Hostname, _ = os.Hostname()
+	flags.VisitAll(func(f *flag.Flag) {
+		h.Flags[f.Name] = f.Value.String()
+	})
+	if h.Flags["s"] != "" {
+		h.Flags["s"] = "REDACTED"
 	}
-	// Wait for deletes to finish
-	doneDeletes.Wait()
-	// If error, it is fatal
+	return h
+}
+
+func (h headerMessage) String() string {
+	msg := "# s3-benchmark " + h.Version
+	if h.Commit != "" {
+		msg += " (" + h.Commit + ")"
+	}
+	msg += fmt.Sprintf(", %s, host %s, started %s\n# flags:", h.GoVersion, h.Hostname, h.Start.Format(http.TimeFormat))
+	names := make([]string, 0, len(h.Flags))
+	for name := range h.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		msg += fmt.Sprintf(" -%s=%q", name, h.Flags[name])
+	}
+	return msg
+}
+
+func (h headerMessage) JSON() string {
+	data, err := json.Marshal(&h)
 	if err != nil {
-		log.Fatalf("FATAL: Unable to delete objects from bucket: %v", err)
+		panic(err)
 	}
+	return string(data)
 }
 
-// canonicalAmzHeaders -- return the x-amz headers canonicalized
-func canonicalAmzHeaders(req *http.Request) string {
-	// Parse out all x-amz headers
-	var headers []string
-	for header := range req.Header {
-		norm := strings.ToLower(strings.TrimSpace(header))
-		if strings.HasPrefix(norm, "x-amz") {
-			headers = append(headers, norm)
+// socketMessage -- the socket buffer sizes requested and those the kernel actually set
+type socketMessage struct {
+	SndBuf       int `json:"sndbuf"`
+	SndBufActual int `json:"sndbufActual"`
+	RcvBuf       int `json:"rcvbuf"`
+	RcvBufActual int `json:"rcvbufActual"`
+}
+
+func (s socketMessage) String() string {
+	size := func(n int) string {
+		if n <= 0 {
+			return "default"
 		}
+		return bytefmt.ByteSize(uint64(n))
 	}
-	// Put them in sorted order
-	sort.Strings(headers)
-	// Now add back the values
-	for n, header := range headers {
-		headers[n] = header + ":" + strings.Replace(req.Header.Get(header), "\n", " ", -1)
-	}
-	// Finally, put them back together
-	if len(headers) > 0 {
-		return strings.Join(headers, "\n") + "\n"
+	return fmt.Sprintf("Socket buffers: sndbuf requested %s, actual %s; rcvbuf requested %s, actual %s.",
+		size(s.SndBuf), size(s.SndBufActual), size(s.RcvBuf), size(s.RcvBufActual))
+}
+
+func (s socketMessage) JSON() string {
+	data, err := json.Marshal(&s)
+	if err != nil {
+		panic(err)
 	}
-	return ""
+	return string(data)
 }
 
-func hmacSHA1(key []byte, content string) []byte {
-	mac := hmac.New(sha1.New, key)
-	mac.Write([]byte(content))
-	return mac.Sum(nil)
+// scalingMessage -- a phase's multi-threaded rate against the -calibrate single-threaded baseline
+type scalingMessage struct {
+	Method     string  `json:"method"`
+	Size       string  `json:"size,omitempty"`
+	Threads    int     `json:"threads"`
+	SingleOps  float64 `json:"singleThreadOperations"`
+	Operations float64 `json:"totalOperations"`
+	Efficiency float64 `json:"efficiency"`
 }
 
-func setSignature(req *http.Request) {
-	// Setup default parameters
-	dateHdr := time.Now().UTC().Format(time.RFC1123)
-	req.Header.Set("X-Amz-Date", dateHdr)
-	// Get the canonical resource and header
-	canonicalResource := req.URL.EscapedPath()
-	canonicalHeaders := canonicalAmzHeaders(req)
-	stringToSign := req.Method + "\n" + req.Header.Get("Content-MD5") + "\n" + req.Header.Get("Content-Type") + "\n\n" +
-		canonicalHeaders + canonicalResource
-	hash := hmacSHA1([]byte(secretKey), stringToSign)
-	signature := base64.StdEncoding.EncodeToString(hash)
-	req.Header.Set("Authorization", fmt.Sprintf("AWS %s:%s", accessKey, signature))
+func newScalingMessage(single, multi logMessage, ops float64) scalingMessage {
+	m := scalingMessage{Method: multi.Method, Size: sizeLabel, Threads: multi.Threads, SingleOps: single.Operations, Operations: ops}
+	if single.Operations > 0 && multi.Threads > 0 {
+		m.Efficiency = ops / (single.Operations * float64(multi.Threads)) * 100
+	}
+	return m
 }
 
-func runUpload(threadNum int) {
-	for time.Now().Before(endtime) {
-		objnum := atomic.AddInt64(&uploadCount, 1)
-		fileobj := bytes.NewReader(objectData)
-		prefix := fmt.Sprintf("%s/%s/Object-%d", urlHost, bucket, objnum)
-		req, _ := http.NewRequest(http.MethodPut, prefix, fileobj)
-		req.Header.Set("Content-Length", strconv.FormatUint(objectSize, 10))
-		setSignature(req)
-		if resp, err := httpClient.Do(req); err != nil {
-			log.Fatalf("FATAL: Error uploading object %s: %v", prefix, err)
-		} else if resp.StatusCode != http.StatusOK {
-			fmt.Printf("Upload status %s: resp: %+v\n", resp.Status, resp)
-			if resp.Body != nil {
-				body, _ := ioutil.ReadAll(resp.Body)
-				fmt.Printf("Body: %s\n", string(body))
-			}
-		}
+func (s scalingMessage) String() string {
+	msg := fmt.Sprintf("Scaling: %s %.1f operations/sec with %d threads, %.1f with 1, %.1f%% efficiency",
+		s.Method, s.Operations, s.Threads, s.SingleOps, s.Efficiency)
+	if s.Size != "" {
+		msg += ", size = " + s.Size
 	}
-	// One less thread
-	wg.Done()
+	return msg + "."
 }
 
-func runDownload(threadNum int) {
-	for time.Now().Before(endtime) {
-		atomic.AddInt64(&downloadCount, 1)
-		objnum := rand.Int63n(uploadCount) + 1
-		prefix := fmt.Sprintf("%s/%s/Object-%d", urlHost, bucket, objnum)
-		req, _ := http.NewRequest(http.MethodGet, prefix, nil)
-		setSignature(req)
-		if resp, err := httpClient.Do(req); err != nil {
-			log.Fatalf("FATAL: Error uploading object %s: %v", prefix, err)
-		} else if resp != nil && resp.Body != nil {
-			io.Copy(ioutil.Discard, resp.Body)
-		}
+func (s scalingMessage) JSON() string {
+	data, err := json.Marshal(&s)
+	if err != nil {
+		panic(err)
 	}
-	// One less thread
-	wg.Done()
+	return string(data)
 }
 
-func runDelete(threadNum int) {
-	for {
-		objnum := atomic.AddInt64(&deleteCount, 1)
-		if objnum > uploadCount {
-			break
-		}
-		prefix := fmt.Sprintf("%s/%s/Object-%d", urlHost, bucket, objnum)
-		req, _ := http.NewRequest(http.MethodDelete, prefix, nil)
-		setSignature(req)
-		if _, err := httpClient.Do(req); err != nil {
-			log.Fatalf("FATAL: Error deleting object %s: %v", prefix, err)
-		}
+// compressionMessage -- how well gzip compresses a sample of the upload data
+type compressionMessage struct {
+	Size   string  `json:"size,omitempty"`
+	Sample uint64  `json:"sampleBytes"`
+	Ratio  float64 `json:"ratio"`
+}
+
+func (c compressionMessage) String() string {
+	msg := fmt.Sprintf("Payload gzip compression ratio %.2f:1 over a %sB sample", c.Ratio, bytefmt.ByteSize(c.Sample))
+	if c.Size != "" {
+		msg += ", size = " + c.Size
 	}
-	// One less thread
-	wg.Done()
+	return msg + "."
 }
 
-func main() {
-	// Parse command line
-	myflag := flag.NewFlagSet("myflag", flag.ExitOnError)
-	myflag.BoolVar(&jsonPrint, "j", false, "Log output in JSON format")
-	myflag.StringVar(&accessKey, "a", "Q3AM3UQ867SPQQA43P2F", "Access key")
-	myflag.StringVar(&secretKey, "s", "zuf+tfteSlswRu7BJ86wekitnifILbZam1KYY3TG", "Secret key")
-	myflag.StringVar(&urlHost, "u", "https://play.min.io", "URL for host with method prefix")
-	myflag.StringVar(&bucket, "b", "s3-benchmark", "Bucket for testing")
-	myflag.IntVar(&durationSecs, "d", 10, "Duration of each test in seconds")
-	myflag.IntVar(&threads, "t", 1, "Number of threads to run")
-	myflag.IntVar(&loops, "l", 1, "Number of times to repeat test")
-	var sizeArg string
-	myflag.StringVar(&sizeArg, "z", "1M", "Size of objects in bytes with postfix K, M, and G")
-	if err := myflag.Parse(os.Args[1:]); err != nil {
-		os.Exit(1)
+func (c compressionMessage) JSON() string {
+	data, err := json.Marshal(&c)
+	if err != nil {
+		panic(err)
 	}
+	return string(data)
+}
 
-	// Hello
-	if !jsonPrint {
-		fmt.Println("S3 benchmark program v3.1")
+// compressionSample -- the most upload data compressionRatio looks at
+const compressionSample = 1 << 20
+
+// compressionRatio -- gzip the start of the upload data, as an inline-compressing backend might
+func compressionRatio() compressionMessage {
+	n := objectSize
+	if n > compressionSample {
+		n = compressionSample
 	}
+	var sample io.Reader
+	if streamData {
+		sample = newStreamReader(1, n)
+	} else {
+		sample = bytes.NewReader(objectData[:n])
+	}
+	var counter countingWriter
+	gz := gzip.NewWriter(&counter)
+	io.Copy(gz, sample)
+	gz.Close()
+	return compressionMessage{Size: sizeLabel, Sample: n, Ratio: float64(n) / float64(counter.n)}
+}
 
-	// Check the arguments
-	if accessKey == "" {
-		log.Fatal("Missing argument -a for access key.")
+// countingWriter -- counts and discards the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// logEntry -- anything logit knows how to print
+type logEntry interface {
+	String() string
+	JSON() string
+}
+
+// resultFile -- a results file, gzip compressed when -compresslog is set
+// The sampler writes while a failing request may be closing it, so the methods take a lock, and once closed
+// the file ignores further writes
+type resultFile struct {
+	mu     sync.Mutex
+	file   *os.File
+	gz     *gzip.Writer
+	closed bool
+}
+
+func openResultFile(path string, flag int) (*resultFile, error) {
+	if compressLog {
+		path += ".gz"
 	}
-	if secretKey == "" {
-		log.Fatal("Missing argument -s for secret key.")
+	file, err := os.OpenFile(path, flag, 0666)
+	if err != nil {
+		return nil, err
 	}
-	var err error
-	if objectSize, err = bytefmt.ToBytes(sizeArg); err != nil {
-		log.Fatalf("Invalid -z argument for object size: %v", err)
+	r := &resultFile{file: file}
+	if compressLog {
+		// Appending starts a new gzip member, which readers treat as one stream
+		r.gz = gzip.NewWriter(file)
 	}
+	return r, nil
+}
 
-	type parameters struct {
-		URLHost  string `json:"urlHost"`
-		Bucket   string `json:"bucket"`
-		Duration int    `json:"duration"`
-		Threads  int    `json:"threads"`
-		Loops    int    `json:"loops"`
-		Size     string `json:"sizeArg"`
+func (r *resultFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, os.ErrClosed
 	}
+	if r.gz != nil {
+		return r.gz.Write(p)
+	}
+	return r.file.Write(p)
+}
 
-	// Echo the parameters
-	if !jsonPrint {
-		fmt.Println(fmt.Sprintf("Parameters: url=%s, bucket=%s, duration=%d, threads=%d, loops=%d, size=%s",
-			urlHost, bucket, durationSecs, threads, loops, sizeArg))
-	} else {
-		data, err := json.Marshal(parameters{
-			URLHost:  urlHost,
-			Bucket:   bucket,
-			Duration: durationSecs,
-			Threads:  threads,
-			Loops:    loops,
-			Size:     sizeArg,
-		})
-		if err != nil {
-			log.Fatal(err)
+// Sync -- push everything written so far to disk
+func (r *resultFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	if r.gz != nil {
+		if err := r.gz.Flush(); err != nil {
+			return err
 		}
-		fmt.Println(string(data))
 	}
+	return r.file.Sync()
+}
 
-	// Initialize data for the bucket
-	objectData = make([]byte, objectSize)
-	rand.Read(objectData)
+func (r *resultFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.gz != nil {
+		// Writes the gzip trailer, without which the file doesn't decompress cleanly
+		r.gz.Close()
+	}
+	return r.file.Close()
+}
 
-	// Create the bucket and delete all the objects
-	createBucket()
-	deleteAllObjects()
+var logfile *resultFile
 
-	// Loop running the tests
-	for loop := 1; loop <= loops; loop++ {
-		uploadCount = 0
-		downloadCount = 0
-		// Run the upload case
-		starttime := time.Now()
-		endtime = starttime.Add(time.Second * time.Duration(durationSecs))
-		wg.Add(threads)
-		for n := 1; n <= threads; n++ {
-			go runUpload(n)
-		}
-		// Wait for it to finish
-		wg.Wait()
-		uploadFinish = time.Now()
-		uploadTime := uploadFinish.Sub(starttime).Seconds()
-
-		bps := float64(uint64(uploadCount)*objectSize) / uploadTime
-		logit(logMessage{
-			LogTime:    time.Now(),
-			Loop:       loop,
-			Method:     http.MethodPut,
-			Time:       uploadTime,
-			Objects:    uploadCount,
-			Speed:      bytefmt.ByteSize(uint64(bps)),
-			RawSpeed:   uint64(bps),
-			Operations: (float64(uploadCount) / uploadTime),
-		})
+// closeResults -- close benchmark.log and the -timeseries file
+func closeResults() {
+	if logfile != nil {
+		logfile.Close()
+	}
+	if timeseriesFile != nil {
+		timeseriesFile.Close()
+	}
+}
 
-		// Run the download case
-		starttime = time.Now()
-		endtime = starttime.Add(time.Second * time.Duration(durationSecs))
-		wg.Add(threads)
-		for n := 1; n <= threads; n++ {
-			go runDownload(n)
-		}
-		// Wait for it to finish
-		wg.Wait()
-		downloadFinish = time.Now()
-		downloadTime := downloadFinish.Sub(starttime).Seconds()
-
-		bps = float64(uint64(downloadCount)*objectSize) / downloadTime
-		logit(logMessage{
-			LogTime:    time.Now(),
-			Loop:       loop,
-			Method:     http.MethodGet,
-			Time:       downloadTime,
-			Objects:    downloadCount,
-			Speed:      bytefmt.ByteSize(uint64(bps)),
-			RawSpeed:   uint64(bps),
-			Operations: (float64(downloadCount) / downloadTime),
-		})
+// fatal -- log.Fatal, closing the result files and the -trace file first so a failed run's results and the
+// records of the requests that led up to it stay readable
+func fatal(v ...interface{}) {
+	closeResults()
+	closeTrace()
+	log.Fatal(v...)
+}
 
-		// Run the delete case
-		starttime = time.Now()
-		endtime = starttime.Add(time.Second * time.Duration(durationSecs))
-		wg.Add(threads)
-		for n := 1; n <= threads; n++ {
-			go runDelete(n)
-		}
+// fatalf -- log.Fatalf, closing the result files and the -trace file first
+func fatalf(format string, v ...interface{}) {
+	closeResults()
+	closeTrace()
+	log.Fatalf(format, v...)
+}
 
-		// Wait for it to finish
-		wg.Wait()
-		deleteFinish = time.Now()
-		deleteTime := deleteFinish.Sub(starttime).Seconds()
-
-		logit(logMessage{
-			LogTime:    time.Now(),
-			Loop:       loop,
-			Method:     http.MethodDelete,
-			Time:       deleteTime,
-			Operations: (float64(uploadCount) / deleteTime),
-		})
+// recordType -- the type field of an -output ndjson line
+func recordType(l logEntry) string {
+	switch l.(type) {
+	case summaryMessage:
+		return "summary"
+	case sweepMessage:
+		return "sweep"
+	case endpointMessage:
+		return "endpoint"
+	case headerMessage:
+		return "header"
+	case socketMessage:
+		return "socket"
+	case scalingMessage:
+		return "scaling"
+	case compressionMessage:
+		return "compression"
+	default:
+		return "phase"
 	}
+}
 
-	// All done
-	if !jsonPrint {
-		fmt.Println("Benchmark completed.")
+// tagJSON -- add a string field to the front of a JSON object, e.g. the -output ndjson record type
+func tagJSON(data, name, value string) string {
+	quoted, _ := json.Marshal(value)
+	return `{"` + name + `":` + string(quoted) + "," + strings.TrimPrefix(data, "{")
+}
+
+func logit(l logEntry) {
+	recordMetrics(l)
+	var msg string
+	if jsonPrint {
+		msg = l.JSON()
+		if runLabel != "" {
+			msg = tagJSON(msg, "label", runLabel)
+		}
+		if ndjson {
+			msg = tagJSON(msg, "type", recordType(l))
+		}
+	} else {
+		msg = l.String()
+	}
+	fmt.Println(msg)
+	if logfile != nil {
+		logfile.Write([]byte(msg + "\n"))
+		// Make sure finished results survive a crash later in the run
+		logfile.Sync()
+	}
+}
+
+var timeseriesFile *resultFile
+var timeseries *csv.Writer
+
+var showSparkline bool
+
+// sparkBlocks -- the bars of a -sparkline, lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkWidth -- the number of seconds a -sparkline shows
+const sparkWidth = 30
+
+// sparkline -- the recent per-second throughput of a phase, redrawn on one line on a terminal
+type sparkline struct {
+	method  string
+	tty     bool
+	history []float64
+}
+
+func newSparkline(method string) *sparkline {
+	s := &sparkline{method: method}
+	if info, err := os.Stdout.Stat(); err == nil {
+		s.tty = info.Mode()&os.ModeCharDevice != 0
+	}
+	return s
+}
+
+// add -- record and show one second's throughput
+func (s *sparkline) add(bps float64) {
+	if !s.tty {
+		// Plain lines for logs and pipes
+		fmt.Printf("%s %sB/sec\n", s.method, bytefmt.ByteSize(uint64(bps)))
+		return
+	}
+	s.history = append(s.history, bps)
+	if len(s.history) > sparkWidth {
+		s.history = s.history[1:]
+	}
+	max := 0.0
+	for _, v := range s.history {
+		max = math.Max(max, v)
+	}
+	bars := make([]rune, len(s.history))
+	for i, v := range s.history {
+		level := 0
+		if max > 0 {
+			level = int(v / max * float64(len(sparkBlocks)-1))
+		}
+		bars[i] = sparkBlocks[level]
+	}
+	fmt.Printf("\r%s %s %sB/sec\x1b[K", s.method, string(bars), bytefmt.ByteSize(uint64(bps)))
+}
+
+// clear -- remove the sparkline so the phase result prints on a clean line
+func (s *sparkline) clear() {
+	if s.tty && len(s.history) > 0 {
+		fmt.Print("\r\x1b[K")
+	}
+}
+
+// startSampler -- record the per-second throughput of a phase until the returned func is called
+func startSampler(loop int, method string) func() {
+	if timeseries == nil && !showSparkline && metricsAddr == "" {
+		return func() {}
+	}
+	setLivePhase(loop, method)
+	var spark *sparkline
+	if showSparkline {
+		spark = newSparkline(method)
+	}
+	quit := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		start := time.Now()
+		last := start
+		var lastOps, lastBytes int64
+		for {
+			select {
+			case <-quit:
+				return
+			case now := <-ticker.C:
+				ops := atomic.LoadInt64(&opsDone)
+				bytes := atomic.LoadInt64(&bytesDone)
+				secs := now.Sub(last).Seconds()
+				if timeseries != nil {
+					row := []string{
+						strconv.Itoa(loop),
+						method,
+						strconv.FormatFloat(now.Sub(start).Seconds(), 'f', 3, 64),
+						strconv.FormatFloat(float64(ops-lastOps)/secs, 'f', 1, 64),
+						strconv.FormatFloat(float64(bytes-lastBytes)/secs, 'f', 0, 64),
+					}
+					if runLabel != "" {
+						row = append(row, runLabel)
+					}
+					timeseries.Write(row)
+					timeseries.Flush()
+					timeseriesFile.Sync()
+				}
+				if spark != nil {
+					spark.add(float64(bytes-lastBytes) / secs)
+				}
+				setLiveRates(float64(ops-lastOps)/secs, float64(bytes-lastBytes)/secs)
+				last, lastOps, lastBytes = now, ops, bytes
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-finished
+		setLivePhase(0, "")
+		if spark != nil {
+			spark.clear()
+		}
+	}
+}
+
+// Socket buffer sizes requested with -sndbuf and -rcvbuf, and those of the first socket they were applied to
+var sndBuf, rcvBuf int
+var actualSndBuf, actualRcvBuf int
+var socketBuffersOnce sync.Once
+
+// controlSocket -- set the requested buffer sizes on each new connection before it connects
+func controlSocket(network, address string, c syscall.RawConn) error {
+	if sndBuf == 0 && rcvBuf == 0 {
+		return nil
+	}
+	var sockErr error
+	if err := c.Control(func(fd uintptr) { sockErr = setSocketBuffers(fd) }); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// noDelay -- whether connections disable Nagle's algorithm, as Go does by default
+var noDelay = true
+
+var dialer = &net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+	Control:   controlSocket,
+}
+
+// dialSlots -- set by -dialconcurrency to a semaphore bounding the connects in progress at once
+var dialSlots chan struct{}
+
+// Dials made during a phase, the nanoseconds they spent waiting for a slot and the longest wait
+var dialCount, dialWaitNanos, dialWaitMax int64
+
+// dialWaitStats -- how long a phase's new connections queued for -dialconcurrency
+type dialWaitStats struct {
+	Dials    int64   `json:"dials"`
+	MeanWait float64 `json:"meanWaitMs"`
+	MaxWait  float64 `json:"maxWaitMs"`
+}
+
+func (d dialWaitStats) String() string {
+	return fmt.Sprintf(", dials = %d, dial wait avg/max = %.1f/%.1f ms", d.Dials, d.MeanWait, d.MaxWait)
+}
+
+// setDialWait -- report the phase's dial queueing, when -dialconcurrency limits it
+func setDialWait(l *logMessage) {
+	if dialSlots == nil {
+		return
+	}
+	dials := atomic.LoadInt64(&dialCount)
+	d := &dialWaitStats{Dials: dials, MaxWait: float64(atomic.LoadInt64(&dialWaitMax)) / float64(time.Millisecond)}
+	if dials > 0 {
+		d.MeanWait = float64(atomic.LoadInt64(&dialWaitNanos)) / float64(dials) / float64(time.Millisecond)
+	}
+	l.DialWait = d
+}
+
+// waitDialSlot -- take a -dialconcurrency slot, recording how long that took
+func waitDialSlot(ctx context.Context) error {
+	start := time.Now()
+	select {
+	case dialSlots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	wait := int64(time.Since(start))
+	atomic.AddInt64(&dialCount, 1)
+	atomic.AddInt64(&dialWaitNanos, wait)
+	for {
+		max := atomic.LoadInt64(&dialWaitMax)
+		if wait <= max || atomic.CompareAndSwapInt64(&dialWaitMax, max, wait) {
+			return nil
+		}
+	}
+}
+
+// dialContext -- dial a connection and apply -nodelay to it
+// Under -dialconcurrency the TCP connect waits for a slot; the TLS handshake follows once it has returned
+func dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if dialSlots != nil {
+		if err := waitDialSlot(ctx); err != nil {
+			return nil, err
+		}
+		defer func() { <-dialSlots }()
+	}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetNoDelay(noDelay)
+	}
+	atomic.AddInt64(&connsOpened, 1)
+	if wireBytes {
+		return wireConn{conn}, nil
+	}
+	return conn, nil
+}
+
+// keepAlive -- cleared by -keepalive=false to open a new connection for every request
+var keepAlive bool
+
+// HTTPTransport - Our HTTP transport used for the roundtripper below
+var HTTPTransport http.RoundTripper = &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	DialContext:           dialContext,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: 0,
+	// Allow an unlimited number of idle connections, raised at startup if there are more threads
+	MaxIdleConnsPerHost: 4096,
+	MaxIdleConns:        0,
+	// But limit their idle time
+	IdleConnTimeout: time.Minute,
+	// Ignore TLS errors
+	TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+}
+
+// Redirects are followed by doRequest so they can be signed again
+var httpClient = &http.Client{
+	Transport: HTTPTransport,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// maxRedirects -- the number of redirects a single request may follow
+const maxRedirects = 10
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// isConnReset -- whether a request failed because the endpoint reset or closed the connection
+func isConnReset(err error) bool {
+	for {
+		switch e := err.(type) {
+		case *url.Error:
+			err = e.Err
+		case *net.OpError:
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		default:
+			if err == io.EOF || err == io.ErrUnexpectedEOF || err == syscall.ECONNRESET {
+				return true
+			}
+			// The transport wraps some of these in errors of its own
+			msg := err.Error()
+			return strings.Contains(msg, "connection reset by peer") || strings.Contains(msg, "server closed idle connection")
+		}
+	}
+}
+
+// sendRequest -- send a request, retrying once when the pooled connection it went out on had been reset
+// Some gateways drop idle connections without closing them, which says nothing about the request itself.
+// A second failure is returned to the caller as usual.
+func sendRequest(req *http.Request) (*http.Response, error) {
+	var reused bool
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused }}
+	resp, err := httpClient.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err == nil || !reused || !isConnReset(err) || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+	atomic.AddInt64(&retryCount, 1)
+	retry := req.WithContext(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return httpClient.Do(retry)
+}
+
+// doRequest -- send a signed request, re-signing it for each redirect the endpoint returns
+func doRequest(req *http.Request) (*http.Response, error) {
+	resp, err := sendRequest(req)
+	for hops := 0; err == nil && isRedirect(resp.StatusCode); hops++ {
+		location, locErr := resp.Location()
+		if locErr != nil {
+			// Nowhere to go, let the caller see the redirect
+			return resp, nil
+		}
+		drainBody(resp)
+		if hops == maxRedirects {
+			return nil, fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		atomic.AddInt64(&redirectCount, 1)
+		next, reqErr := http.NewRequest(req.Method, location.String(), nil)
+		if reqErr != nil {
+			return nil, reqErr
+		}
+		// The context carries the phase deadline, Ctrl-C and any -breakdown trace
+		next = next.WithContext(req.Context())
+		if req.Host != req.URL.Host {
+			// A -hosthdr override
+			next.Host = req.Host
+		}
+		if req.GetBody != nil {
+			if next.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+			next.GetBody = req.GetBody
+			next.ContentLength = req.ContentLength
+		}
+		for header, values := range req.Header {
+			next.Header[header] = values
+		}
+		req = next
+		signRequest(req)
+		resp, err = sendRequest(req)
+	}
+	if err == nil && failFast {
+		checkFailFast(req, resp)
+	}
+	return resp, err
+}
+
+// failFast -- set by -failfast to stop when a phase's first requests are all refused, as a wrong key or
+// missing permission makes them; the preflight only tries one PUT and GET
+var failFast bool
+
+// failFastRequests -- how many refusals at the start of a phase stop the run under -failfast
+const failFastRequests = 3
+
+// Requests of the phase -failfast has looked at, and how many of those the server refused
+var failFastSeen, failFastDenied int64
+
+// checkFailFast -- stop with what was signed once a phase's first failFastRequests requests have all been refused
+func checkFailFast(req *http.Request, resp *http.Response) {
+	if atomic.AddInt64(&failFastSeen, 1) > failFastRequests {
+		return
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		// Something got through, auth works; stop checking
+		atomic.StoreInt64(&failFastSeen, failFastRequests)
+		return
+	}
+	if atomic.AddInt64(&failFastDenied, 1) < failFastRequests {
+		return
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	headers := make([]string, 0, len(req.Header))
+	for name, values := range req.Header {
+		headers = append(headers, name+": "+strings.Join(values, ","))
+	}
+	sort.Strings(headers)
+	fatalf("FATAL: The first %d requests of the phase were refused with %s, check the keys and the bucket's permissions\n"+
+		"Last request: %s %s\nString to sign: %q\nHeaders:\n  %s\nResponse: %s",
+		failFastRequests, resp.Status, req.Method, req.URL, stringToSign(req), strings.Join(headers, "\n  "), body)
+}
+
+// signingService -- set by -service, the service name in the SigV4 credential scope of the SDK requests
+var signingService string
+
+func getS3Client() *s3.S3 {
+	// Build our config
+	creds := credentials.NewStaticCredentials(accessKey, secretKey, "")
+	loglevel := aws.LogOff
+	// Build the rest of the configuration
+	awsConfig := &aws.Config{
+		Region:               aws.String(region),
+		Endpoint:             aws.String(urlHost),
+		Credentials:          creds,
+		LogLevel:             &loglevel,
+		S3ForcePathStyle:     aws.Bool(!accelerate),
+		S3UseAccelerate:      aws.Bool(accelerate),
+		S3Disable100Continue: aws.Bool(!expect100),
+		MaxRetries:           aws.Int(sdkRetries),
+		// Comment following to use default transport
+		HTTPClient: &http.Client{Transport: httpClient.Transport},
+	}
+	session := session.New(awsConfig)
+	client := s3.New(session)
+	if client == nil {
+		fatalf("FATAL: Unable to create new client.")
+	}
+	if signingService != "s3" {
+		// The V4 signer scopes the signature to the request's SigningName, but also only treats the request as S3's,
+		// with unescaped paths and a signed payload hash, when that name is s3, so keep both itself
+		client.Handlers.Sign.RemoveByName(v4.SignRequestHandler.Name)
+		client.Handlers.Sign.PushBackNamed(v4.BuildNamedHandler(v4.SignRequestHandler.Name, func(s *v4.Signer) {
+			s.DisableURIPathEscaping = true
+		}))
+		client.Handlers.Sign.PushFront(func(r *request.Request) {
+			r.ClientInfo.SigningName = signingService
+			r.HTTPRequest.Header.Set("X-Amz-Content-Sha256", payloadHash(r.GetBody()))
+		})
+	}
+	// Return success
+	return client
+}
+
+// payloadHash -- the hex SHA-256 of a request body, leaving the body where it was
+func payloadHash(body io.ReadSeeker) string {
+	h := sha256.New()
+	if body != nil {
+		start, _ := body.Seek(0, io.SeekCurrent)
+		io.Copy(h, body)
+		body.Seek(start, io.SeekStart)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isErrorCode -- whether err is an SDK error with the given S3 error code
+// Compare codes rather than messages, whose wording changes between SDK versions
+func isErrorCode(err error, code string) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == code
+}
+
+func createBucket() {
+	// Get a client
+	client := getS3Client()
+	// Create our bucket (may already exist without error)
+	in := &s3.CreateBucketInput{Bucket: aws.String(bucket)}
+	if _, err := client.CreateBucket(in); err != nil {
+		if !isErrorCode(err, s3.ErrCodeBucketAlreadyOwnedByYou) && !isErrorCode(err, s3.ErrCodeBucketAlreadyExists) {
+			fatalf("FATAL: Unable to create bucket %s (is your access and secret correct?): %v", bucket, err)
+		}
+	}
+	waitForBucket(client)
+}
+
+// bucketWaitSecs -- how long to wait for a new bucket to be usable, 0 not to check
+var bucketWaitSecs int
+
+// waitForBucket -- HEAD the bucket until it answers, as some backends create buckets eventually
+// and would otherwise fail the first PUTs with NoSuchBucket
+func waitForBucket(client *s3.S3) {
+	if bucketWaitSecs == 0 {
+		return
+	}
+	deadline := time.Now().Add(time.Second * time.Duration(bucketWaitSecs))
+	for {
+		_, err := client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(bucket)})
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			fatalf("FATAL: Bucket %s was still not ready after %d seconds: %v", bucket, bucketWaitSecs, err)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// preflight -- make one signed PUT, GET and DELETE so a signing problem fails fast instead of mid-run
+// The DELETE removes the object again, as the cleanup may not cover it and it would count as the run's own
+func preflight() {
+	url := bucketURL() + "/s3-benchmark-preflight"
+	for _, method := range []string{http.MethodPut, http.MethodGet, http.MethodDelete} {
+		var body io.Reader
+		if method == http.MethodPut {
+			body = strings.NewReader("preflight")
+		}
+		req := newRequest(method, url, body)
+		if body != nil && chunkedUpload {
+			// Find out now if the endpoint refuses chunked uploads
+			setChunked(req)
+		}
+		signRequest(req)
+		resp, err := doRequest(req)
+		if err != nil {
+			fatalf("FATAL: Pre-flight %s %s failed: %v", method, url, err)
+		}
+		msg, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusForbidden || bytes.Contains(msg, []byte("SignatureDoesNotMatch")) {
+			fatalf("FATAL: Pre-flight %s %s was rejected with status %s, check the keys and that the endpoint accepts "+
+				"AWS signature version 2\nString to sign: %q\nBody: %s", method, url, resp.Status, stringToSign(resp.Request), msg)
+		}
+		if resp.StatusCode != http.StatusOK && !(method == http.MethodDelete && resp.StatusCode == http.StatusNoContent) {
+			fatalf("FATAL: Pre-flight %s %s failed with status %s\nBody: %s", method, url, resp.Status, msg)
+		}
+	}
+}
+
+// Limits on the startup cleanup, for buckets shared with other data
+var cleanPrefix string
+var maxClean int64
+var forceClean bool
+
+// cleanThreads -- the most DeleteObjects calls of 1000 keys the cleanup makes at once
+var cleanThreads int
+
+// cleanLister -- pages through the objects the startup cleanup deletes, every version of them so a versioned bucket
+// is emptied of old versions and delete markers too, unless the endpoint doesn't list versions
+type cleanLister struct {
+	client                   *s3.S3
+	objectsOnly              bool
+	keyMarker, versionMarker *string
+	started, done            bool
+}
+
+// next -- the next page of up to 1000 objects to delete
+func (l *cleanLister) next() ([]*s3.ObjectIdentifier, error) {
+	var objects []*s3.ObjectIdentifier
+	var truncated *bool
+	if !l.objectsOnly {
+		in := &s3.ListObjectVersionsInput{Bucket: aws.String(bucket), KeyMarker: l.keyMarker,
+			VersionIdMarker: l.versionMarker, MaxKeys: aws.Int64(1000), Prefix: aws.String(cleanPrefix)}
+		versions, err := l.client.ListObjectVersions(in)
+		if err != nil {
+			if !l.started && !isErrorCode(err, s3.ErrCodeNoSuchBucket) {
+				// Not every S3-compatible endpoint lists versions, list the objects instead
+				l.objectsOnly = true
+				return l.next()
+			}
+			return nil, err
+		}
+		for _, version := range versions.Versions {
+			objects = append(objects, &s3.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+		}
+		for _, marker := range versions.DeleteMarkers {
+			objects = append(objects, &s3.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+		}
+		truncated = versions.IsTruncated
+		l.keyMarker, l.versionMarker = versions.NextKeyMarker, versions.NextVersionIdMarker
+	} else {
+		in := &s3.ListObjectsInput{Bucket: aws.String(bucket), Marker: l.keyMarker, MaxKeys: aws.Int64(1000),
+			Prefix: aws.String(cleanPrefix)}
+		listObjects, err := l.client.ListObjects(in)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range listObjects.Contents {
+			objects = append(objects, &s3.ObjectIdentifier{Key: object.Key})
+			l.keyMarker = object.Key
+		}
+		truncated = listObjects.IsTruncated
+		if listObjects.NextMarker != nil {
+			l.keyMarker = listObjects.NextMarker
+		}
+	}
+	l.started = true
+	l.done = truncated == nil || !*truncated
+	return objects, nil
+}
+
+// deleteAllObjects -- empty the bucket, or the part of it under -cleanprefix, before the run
+func deleteAllObjects() {
+	// If error, it is fatal
+	if err := cleanBucket(); err != nil {
+		fatalf("FATAL: Unable to delete objects from bucket: %v", err)
+	}
+}
+
+// cleanBucket -- delete the objects deleteAllObjects empties the bucket of
+// With -maxclean the objects are counted first, and none are deleted if there are more than that, unless
+// -forceclean is set
+func cleanBucket() error {
+	// Get a client
+	client := getS3Client()
+	if maxClean > 0 && !forceClean {
+		lister := &cleanLister{client: client}
+		var found int64
+		for !lister.done && found <= maxClean {
+			objects, err := lister.next()
+			if isErrorCode(err, s3.ErrCodeNoSuchBucket) {
+				// The bucket may not exist, just ignore in that case
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("listing objects unexpected failure: %v", err)
+			}
+			found += int64(len(objects))
+		}
+		if found > maxClean {
+			return fmt.Errorf("refusing to delete more than %d objects, use -forceclean to delete them all or -cleanprefix to delete fewer", maxClean)
+		}
+	}
+	// Use up to -cleanthreads routines to do the actual delete, listing waits for one to be free
+	var doneDeletes sync.WaitGroup
+	slots := make(chan struct{}, cleanThreads)
+	var deleteErr error
+	var errMu sync.Mutex
+	// Loop deleting reading as big a list as we can
+	lister := &cleanLister{client: client}
+	for !lister.done {
+		objects, err := lister.next()
+		if isErrorCode(err, s3.ErrCodeNoSuchBucket) {
+			// The bucket may not exist, just ignore in that case
+			break
+		} else if err != nil {
+			doneDeletes.Wait()
+			return fmt.Errorf("listing objects unexpected failure: %v", err)
+		}
+		if len(objects) == 0 {
+			continue
+		}
+		// Start a delete routine
+		doDelete := func(bucket string, delete *s3.Delete) {
+			if _, e := client.DeleteObjects(
+				&s3.DeleteObjectsInput{
+					Bucket: aws.String(bucket),
+					Delete: delete,
+				}); e != nil {
+				errMu.Lock()
+				deleteErr = fmt.Errorf("DeleteObjects unexpected failure: %s", e.Error())
+				errMu.Unlock()
+			}
+			<-slots
+			doneDeletes.Done()
+		}
+		slots <- struct{}{}
+		doneDeletes.Add(1)
+		go doDelete(bucket, &s3.Delete{Objects: objects, Quiet: aws.Bool(true)})
+	}
+	// Wait for deletes to finish
+	doneDeletes.Wait()
+	return deleteErr
+}
+
+// singleKey -- set by -singlekey to GET this one existing key instead of the uploaded objects
+var singleKey string
+
+// checkSingleKey -- make sure the -singlekey object exists before benchmarking it
+func checkSingleKey() {
+	_, err := getS3Client().HeadObject(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(singleKey)})
+	if err != nil {
+		fatalf("FATAL: -singlekey object %s/%s is not readable: %v", bucket, singleKey, err)
+	}
+}
+
+// objectNumber -- parse the object number back out of a key made by objectKey
+func objectNumber(key string) (int64, bool) {
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		key = key[i+1:]
+	}
+	if !strings.HasPrefix(key, "Object-") || !strings.HasSuffix(key, objectSuffix) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(key, "Object-"), objectSuffix), 10, 64)
+	return n, err == nil && n > 0
+}
+
+// findResumePoint -- list the bucket for the highest object number a previous run uploaded
+func findResumePoint() int64 {
+	client := getS3Client()
+	var highest int64
+	var marker *string
+	for {
+		in := &s3.ListObjectsInput{Bucket: aws.String(bucket), Marker: marker, MaxKeys: aws.Int64(1000)}
+		list, err := client.ListObjects(in)
+		if err != nil {
+			fatalf("FATAL: Unable to list bucket %s to resume: %v", bucket, err)
+		}
+		for _, object := range list.Contents {
+			if n, ok := objectNumber(aws.StringValue(object.Key)); ok && n > highest {
+				highest = n
+			}
+			marker = object.Key
+		}
+		if list.IsTruncated == nil || !*list.IsTruncated || len(list.Contents) == 0 {
+			break
+		}
+	}
+	if objectVersions > 1 {
+		// Each key holds that many object numbers
+		highest *= int64(objectVersions)
+	}
+	return highest
+}
+
+// verifyCount -- set by -verifycount to list the bucket after the PUT phase and compare its objects with the uploads
+var verifyCount bool
+
+// countObjects -- list the bucket, under -cleanprefix if set, and count the objects in it
+func countObjects() int64 {
+	client := getS3Client()
+	var count int64
+	var marker *string
+	for {
+		in := &s3.ListObjectsInput{Bucket: aws.String(bucket), Marker: marker, MaxKeys: aws.Int64(1000),
+			Prefix: aws.String(cleanPrefix)}
+		list, err := client.ListObjects(in)
+		if err != nil {
+			fatalf("FATAL: Unable to list bucket %s to count its objects: %v", bucket, err)
+		}
+		for _, object := range list.Contents {
+			count++
+			marker = object.Key
+		}
+		if list.IsTruncated == nil || !*list.IsTruncated || len(list.Contents) == 0 {
+			break
+		}
+	}
+	return count
+}
+
+// checkObjectCount -- under -verifycount, warn when the bucket doesn't hold the objects the PUT phase reported
+// writing: one per successful upload, less those deleted in the background
+func checkObjectCount(loop int, put *logMessage) {
+	if !verifyCount {
+		return
+	}
+	expected := uploadCount - put.Errors - bgDeleted
+	listed := countObjects()
+	put.Listed = &listed
+	if listed != expected {
+		log.Printf("WARNING: Loop %d: the bucket lists %d objects after the PUT phase, expected %d from %d uploads, %d failed "+
+			"and %d deleted in the background; uploads may have failed silently, or the listing may lag behind",
+			loop, listed, expected, uploadCount, put.Errors, bgDeleted)
+	}
+}
+
+// canonicalAmzHeaders -- return the x-amz headers canonicalized
+func canonicalAmzHeaders(req *http.Request) string {
+	// Parse out all x-amz headers
+	var headers []string
+	for header := range req.Header {
+		norm := strings.ToLower(strings.TrimSpace(header))
+		if strings.HasPrefix(norm, "x-amz") {
+			headers = append(headers, norm)
+		}
+	}
+	// Put them in sorted order
+	sort.Strings(headers)
+	// Now add back the values
+	for n, header := range headers {
+		headers[n] = header + ":" + strings.Replace(req.Header.Get(header), "\n", " ", -1)
+	}
+	// Finally, put them back together
+	if len(headers) > 0 {
+		return strings.Join(headers, "\n") + "\n"
+	}
+	return ""
+}
+
+func hmacSHA1(key []byte, content string) []byte {
+	mac := hmac.New(sha1.New, key)
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
+
+// signedSubresources -- the query parameters SigV2 includes in the canonical resource
+var signedSubresources = map[string]bool{
+	"acl": true, "delete": true, "lifecycle": true, "location": true, "logging": true, "notification": true,
+	"partNumber": true, "policy": true, "requestPayment": true, "tagging": true, "torrent": true,
+	"uploadId": true, "uploads": true, "versionId": true, "versioning": true, "versions": true, "website": true,
+	"response-cache-control": true, "response-content-disposition": true, "response-content-encoding": true,
+	"response-content-language": true, "response-content-type": true, "response-expires": true,
+}
+
+// canonicalSubresources -- return the signed subresources of a request, sorted, as a query string
+func canonicalSubresources(req *http.Request) string {
+	query := req.URL.Query()
+	var names []string
+	for name := range query {
+		if signedSubresources[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if value := query.Get(name); value != "" {
+			names[i] = name + "=" + value
+		}
+	}
+	return "?" + strings.Join(names, "&")
+}
+
+// stringToSign -- the SigV2 string to sign for a request that already carries its X-Amz-Date
+func stringToSign(req *http.Request) string {
+	// Get the canonical resource and header
+	canonicalResource := req.URL.EscapedPath() + canonicalSubresources(req)
+	if accelerate {
+		// The bucket moved into the host name, but SigV2 still signs it as part of the resource
+		canonicalResource = "/" + bucket + canonicalResource
+	}
+	canonicalHeaders := canonicalAmzHeaders(req)
+	// The Date line is empty when X-Amz-Date, one of the canonical headers, dates the request instead
+	return req.Method + "\n" + req.Header.Get("Content-MD5") + "\n" + req.Header.Get("Content-Type") + "\n" +
+		req.Header.Get("Date") + "\n" + canonicalHeaders + canonicalResource
+}
+
+// dateStyle -- set by -datestyle to date requests with X-Amz-Date (amz) or the standard Date header (date)
+var dateStyle string
+
+func setSignature(req *http.Request) {
+	// Setup default parameters
+	if dateStyle == "date" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	} else {
+		dateHdr := time.Now().UTC().Format(time.RFC1123)
+		req.Header.Set("X-Amz-Date", dateHdr)
+	}
+	hash := hmacSHA1([]byte(secretKey), stringToSign(req))
+	signature := base64.StdEncoding.EncodeToString(hash)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS %s:%s", accessKey, signature))
+}
+
+// anonymousPhases -- the phases -anonymous sends unsigned, by lower case method or "attributes"
+var anonymousPhases = map[string]bool{}
+
+// signRequest -- sign a request unless -anonymous covers its phase
+func signRequest(req *http.Request) {
+	phase := strings.ToLower(req.Method)
+	if _, ok := req.URL.Query()["attributes"]; ok {
+		phase = "attributes"
+	}
+	if anonymousPhases[phase] {
+		return
+	}
+	if !profileSigning {
+		setSignature(req)
+		return
+	}
+	start := time.Now()
+	setSignature(req)
+	atomic.AddInt64(&signNanos, int64(time.Since(start)))
+	atomic.AddInt64(&signCount, 1)
+}
+
+// profileSigning -- set by -profilesigning to time setSignature apart from the requests
+var profileSigning bool
+
+// Nanoseconds spent signing during a phase, and the signatures computed
+var signNanos, signCount int64
+
+// signingStats -- the cost of signing a phase's requests
+type signingStats struct {
+	Total      float64 `json:"totalMs"`
+	PerRequest float64 `json:"perRequestUs"`
+	Share      float64 `json:"share"`
+}
+
+func (s signingStats) String() string {
+	return fmt.Sprintf(", signing = %.1f ms total, %.1f us/request, %.2f%% of request time", s.Total, s.PerRequest, s.Share)
+}
+
+// setSigning -- report the phase's signing time, as a share of the time spent signing and in requests
+// Signing happens before each request's timer starts, so it is not part of the latencies
+func setSigning(l *logMessage) {
+	if !profileSigning || signCount == 0 {
+		return
+	}
+	l.Signing = &signingStats{
+		Total:      float64(signNanos) / float64(time.Millisecond),
+		PerRequest: float64(signNanos) / float64(signCount) / float64(time.Microsecond),
+		Share:      float64(signNanos) / float64(signNanos+requestNanos) * 100,
+	}
+}
+
+// listStats -- what a LIST phase's listings returned
+type listStats struct {
+	Delimiter string  `json:"delimiter"`
+	Keys      float64 `json:"keysPerSec"`
+	Prefixes  float64 `json:"commonPrefixesPerSec"`
+}
+
+func (l listStats) String() string {
+	return fmt.Sprintf(", %.1f keys/sec and %.1f common prefixes/sec with delimiter %q", l.Keys, l.Prefixes, l.Delimiter)
+}
+
+// attemptStats -- how many requests a phase sent for the ones that succeeded
+type attemptStats struct {
+	Attempts   int64   `json:"attempts"`
+	Successes  int64   `json:"successes"`
+	PerSuccess float64 `json:"attemptsPerSuccess"`
+}
+
+func (a attemptStats) String() string {
+	return fmt.Sprintf(", attempts = %d for %d successes, %.3f attempts/success", a.Attempts, a.Successes, a.PerSuccess)
+}
+
+// setAttempts -- report the requests sent per successful one, which retries, redirects and failures raise
+// even when the throughput looks fine: each connection retry and redirect hop is another attempt at the same
+// request. The SDK's own retries are out of sight and not counted.
+func setAttempts(l *logMessage) {
+	ops := atomic.LoadInt64(&opsDone)
+	if ops == 0 {
+		return
+	}
+	attempts := ops + atomic.LoadInt64(&retryCount) + atomic.LoadInt64(&redirectCount)
+	successes := ops - atomic.LoadInt64(&errorCount)
+	a := &attemptStats{Attempts: attempts, Successes: successes}
+	if successes > 0 {
+		a.PerSuccess = float64(attempts) / float64(successes)
+	}
+	l.Attempts = a
+}
+
+// utilization -- percentage of the phase's thread time spent inside requests
+func utilization(elapsed float64) float64 {
+	running := phaseThreads
+	if threadPool > 0 && threadPool < running {
+		running = threadPool
+	}
+	if connections > 0 && connections < running {
+		running = connections
+	}
+	available := elapsed * float64(time.Second) * float64(running)
+	if available <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&requestNanos)) / available * 100
+}
+
+// drainBody -- read the rest of a response body and close it so the connection can be reused
+func drainBody(resp *http.Response) int64 {
+	if resp == nil || resp.Body == nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	n, _ := io.Copy(ioutil.Discard, resp.Body)
+	return n
+}
+
+// streamReader -- produces pseudo-random object data on the fly instead of holding it in memory
+type streamReader struct {
+	rng       *rand.Rand
+	remaining uint64
+}
+
+func newStreamReader(seed int64, size uint64) *streamReader {
+	return &streamReader{rng: rand.New(rand.NewSource(seed)), remaining: size}
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+	if uint64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, _ := r.rng.Read(p)
+	r.remaining -= uint64(n)
+	return n, nil
+}
+
+// parseSize -- parse a byte count, either plain or with a K, M, G or T postfix (fractions allowed)
+func parseSize(arg string) (uint64, error) {
+	s := strings.TrimSpace(arg)
+	tooLarge := fmt.Errorf("size %q is not a finite number of at most %d bytes", arg, int64(math.MaxInt64))
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		if n > math.MaxInt64 {
+			return 0, tooLarge
+		}
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if f < 0 {
+			return 0, fmt.Errorf("size must not be negative")
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) || f > math.MaxInt64 {
+			return 0, tooLarge
+		}
+		return uint64(math.Round(f)), nil
+	}
+	// Let bytefmt handle the units, it does not like a space before them
+	i := strings.IndexFunc(s, unicode.IsLetter)
+	if i > 0 {
+		number, unit := strings.TrimSpace(s[:i]), s[i:]
+		s = number + unit
+		// bytefmt converts whatever the number comes to without checking that it fits
+		if f, err := strconv.ParseFloat(number, 64); err == nil {
+			if scale, err := bytefmt.ToBytes("1" + unit); err == nil && !(f*float64(scale) <= math.MaxInt64) {
+				return 0, tooLarge
+			}
+		}
+	}
+	return bytefmt.ToBytes(s)
+}
+
+// logRequestError -- print a failed response with the request IDs the backend assigned to it
+func logRequestError(op, url string, resp *http.Response) {
+	var body []byte
+	if resp.Body != nil {
+		body, _ = ioutil.ReadAll(resp.Body)
+	}
+	out := os.Stdout
+	if ndjson {
+		// Keep stdout to result records only
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "%s %s failed with status %s (x-amz-request-id: %s, x-amz-id-2: %s)\nBody: %s\n", op, url, resp.Status,
+		resp.Header.Get("X-Amz-Request-Id"), resp.Header.Get("X-Amz-Id-2"), string(body))
+	if bytes.Contains(body, []byte("RequestTimeTooSkewed")) {
+		skewWarning.Do(func() { warnClockSkew(body, resp) })
+	}
+}
+
+var skewWarning sync.Once
+
+// warnClockSkew -- explain a RequestTimeTooSkewed error with an estimate of how far off the local clock is
+func warnClockSkew(body []byte, resp *http.Response) {
+	var skewErr struct {
+		ServerTime string `xml:"ServerTime"`
+	}
+	xml.Unmarshal(body, &skewErr)
+	serverTime, err := time.Parse(time.RFC3339, skewErr.ServerTime)
+	if err != nil {
+		// Fall back on the response date, which only has second resolution
+		if serverTime, err = http.ParseTime(resp.Header.Get("Date")); err != nil {
+			log.Print("WARNING: The server rejected a request as too skewed from its clock, check the local clock is correct")
+			return
+		}
+	}
+	skew, direction := time.Since(serverTime).Round(time.Second), "ahead of"
+	if skew < 0 {
+		skew, direction = -skew, "behind"
+	}
+	log.Printf("WARNING: The server rejected a request as too skewed from its clock, the local clock appears to be "+
+		"about %v %s the server's; correct it (e.g. with NTP) and run again", skew, direction)
+}
+
+// keyFormat -- set by -keyformat: seq for Object-N keys, or random or uuid for keys derived from N and -seed
+var keyFormat string
+
+// padWidth -- set by -padwidth to zero-pad the number in Object-N keys to this many digits, so they list in
+// numeric order
+var padWidth int
+
+// keySeed -- set by -seed, so a later run with the same seed regenerates the random and uuid keys
+var keySeed int64
+
+// objectKey -- return the key used for the given object number
+// Keys are never stored: every phase regenerates them from the object number, so memory does not grow
+// with the object count. Any new key format must likewise be a pure function of the object number
+// (and fixed settings such as -suffix or -keydepth) or GETs and DELETEs will miss the uploaded objects.
+func objectKey(objnum int64) string {
+	if objectVersions > 1 {
+		// Consecutive object numbers are versions of the same key
+		objnum = (objnum-1)/int64(objectVersions) + 1
+	}
+	var key string
+	switch keyFormat {
+	case "random":
+		// mix64 is a bijection, so distinct object numbers keep distinct keys
+		key = fmt.Sprintf("%016x%s", mix64(uint64(objnum)^mix64(uint64(keySeed))), objectSuffix)
+	case "uuid":
+		hi := mix64(uint64(objnum) ^ mix64(uint64(keySeed)))
+		lo := mix64(hi)
+		// Version 4 and RFC 4122 variant bits, so the keys look like the random UUIDs they stand in for
+		hi = hi&^0xf000 | 0x4000
+		lo = lo&^(0xc<<60) | 0x8<<60
+		key = fmt.Sprintf("%08x-%04x-%04x-%04x-%012x%s", hi>>32, hi>>16&0xffff, hi&0xffff, lo>>48, lo&0xffffffffffff, objectSuffix)
+	default:
+		key = fmt.Sprintf("Object-%0*d%s", padWidth, objnum, objectSuffix)
+	}
+	if keyDepth == 0 {
+		return key
+	}
+	// Pseudo-random directories derived from the object number, so every phase finds the same key
+	var dirs strings.Builder
+	h := uint64(objnum)
+	for i := 0; i < keyDepth; i++ {
+		h = mix64(h)
+		fmt.Fprintf(&dirs, "%02x/", h&0xff)
+	}
+	return dirs.String() + key
+}
+
+// Version IDs returned by uploads with -versions, by object number, cleared each loop
+var versionIDs = map[int64]string{}
+var versionMu sync.Mutex
+
+func setVersionID(objnum int64, id string) {
+	versionMu.Lock()
+	versionIDs[objnum] = id
+	versionMu.Unlock()
+}
+
+// queryFlag -- the repeatable -query flag, each value a key=value pair to add to object request URLs
+type queryFlag []string
+
+func (q *queryFlag) String() string {
+	return strings.Join(*q, "&")
+}
+
+func (q *queryFlag) Set(value string) error {
+	i := strings.Index(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected key=value")
+	}
+	*q = append(*q, url.QueryEscape(value[:i])+"="+url.QueryEscape(value[i+1:]))
+	return nil
+}
+
+// extraQuery -- the -query parameters, already escaped
+// SigV2 only signs the subresources in signedSubresources, which canonicalSubresources sorts, so any others ride unsigned
+var extraQuery queryFlag
+
+// objectURL -- the URL of an object, with an optional subresource and the version ID its upload returned
+func objectURL(objnum int64, subresource string) string {
+	return keyURL(objectKey(objnum), objnum, subresource)
+}
+
+// keyURL -- the URL of a key, e.g. the -singlekey, for object objnum
+func keyURL(key string, objnum int64, subresource string) string {
+	var query []string
+	if subresource != "" {
+		query = append(query, subresource)
+	}
+	query = append(query, extraQuery...)
+	if objectVersions > 1 {
+		versionMu.Lock()
+		id := versionIDs[objnum]
+		versionMu.Unlock()
+		if id != "" {
+			query = append(query, "versionId="+url.QueryEscape(id))
+		}
+	}
+	prefix := bucketURL() + "/" + key
+	if len(query) > 0 {
+		prefix += "?" + strings.Join(query, "&")
+	}
+	return prefix
+}
+
+// accelerate -- set by -accelerate to send requests to the bucket's S3 Transfer Acceleration endpoint
+var accelerate bool
+
+// accelerateHost -- the Transfer Acceleration host that -accelerate rewrites the endpoint to
+const accelerateHost = "s3-accelerate.amazonaws.com"
+
+// accelerateEndpoint -- rewrite an endpoint URL to the Transfer Acceleration host, keeping its scheme
+func accelerateEndpoint(endpoint string) string {
+	scheme := "https"
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		scheme = endpoint[:i]
+	}
+	return scheme + "://" + accelerateHost
+}
+
+// accelerateBucketName -- whether a bucket name can be used with Transfer Acceleration: a DNS label of 3 to 63
+// lower case letters, digits and hyphens, with no dots
+func accelerateBucketName(name string) bool {
+	if len(name) < 3 || len(name) > 63 || name[0] == '-' || name[len(name)-1] == '-' {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// bucketURL -- the URL of the bucket, path style or, with -accelerate, with the bucket in the host name
+// as the acceleration endpoint requires
+func bucketURL() string {
+	if accelerate {
+		i := strings.Index(urlHost, "://") + len("://")
+		return urlHost[:i] + bucket + "." + urlHost[i:]
+	}
+	return urlHost + "/" + bucket
+}
+
+// showBanner -- set by -banner to finish with a PASS/FAIL summary instead of stopping at the first threshold failure
+var showBanner bool
+
+// thresholdFailures -- the threshold checks that failed, for the -banner
+var thresholdFailures []string
+
+// thresholdFailed -- abort the benchmark, or with -banner note the failure and carry on to the end
+func thresholdFailed(format string, args ...interface{}) {
+	if !showBanner {
+		fatalf("FATAL: "+format, args...)
+	}
+	msg := fmt.Sprintf(format, args...)
+	fmt.Println("FAIL: " + msg)
+	thresholdFailures = append(thresholdFailures, msg)
+}
+
+// checkThroughput -- abort the benchmark if a phase ran slower than the -minthroughput floor
+func checkThroughput(loop int, method string, bps float64) {
+	if minThroughput > 0 && bps < float64(minThroughput) {
+		thresholdFailed("Loop %d: %s speed %sB/sec is below the minimum of %sB/sec",
+			loop, method, bytefmt.ByteSize(uint64(bps)), bytefmt.ByteSize(minThroughput))
+	}
+}
+
+// checkLatency -- abort the benchmark if a phase's p99 latency exceeded the -p99-max ceiling
+func checkLatency(l logMessage) {
+	if maxP99 > 0 && l.LatencyP99 > maxP99 {
+		thresholdFailed("Loop %d: %s p99 latency %.2f ms is above the maximum of %v ms",
+			l.Loop, l.Method, l.LatencyP99, maxP99)
+	}
+}
+
+// printBanner -- the -banner: the outcome, the mean throughput of each phase over the whole run, errors and time
+func printBanner(total summaryMessage, headline sweepMessage) {
+	result := "PASS"
+	if len(thresholdFailures) > 0 {
+		result = "FAIL"
+	}
+	rule := strings.Repeat("=", 60)
+	fmt.Println(rule)
+	fmt.Printf("s3-benchmark result: %s\n", result)
+	for _, failure := range thresholdFailures {
+		fmt.Println("  " + failure)
+	}
+	fmt.Printf("PUT     %sB/sec, %.1f operations/sec\n", bytefmt.ByteSize(headline.PutSpeed), headline.PutOps)
+	fmt.Printf("GET     %sB/sec, %.1f operations/sec\n", bytefmt.ByteSize(headline.GetSpeed), headline.GetOps)
+	fmt.Printf("DELETE  %.1f operations/sec\n", headline.DeleteOps)
+	fmt.Printf("Errors  %d\n", total.Errors)
+	fmt.Printf("Time    %.1f secs\n", total.Time)
+	fmt.Println(rule)
+}
+
+// latencySet -- request latencies of a phase, one slice per thread so recording needs no locking
+type latencySet [][]time.Duration
+
+func newLatencySet(count int) latencySet {
+	return make(latencySet, count+1)
+}
+
+func (s latencySet) record(threadNum int, elapsed time.Duration) {
+	if atomic.LoadInt32(&rampingUp) != 0 {
+		// The phase reports only the requests after the ramp-up
+		return
+	}
+	s[threadNum] = append(s[threadNum], elapsed)
+}
+
+// rampingUp -- set while a phase's threads are starting over -rampup, to leave those requests out of the latencies
+var rampingUp int32
+
+// rampErrors -- the errors of a phase during its ramp-up, left out of the phase's error count but not the total
+var rampErrors int64
+
+// The bytes moved and read by the end of the ramp-up, which the phase's speed leaves out
+var rampBytes, rampReadBytes int64
+
+// sorted -- return all the samples of the set in ascending order
+func (s latencySet) sorted() []time.Duration {
+	var all []time.Duration
+	for _, samples := range s {
+		all = append(all, samples...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	return all
+}
+
+// recordRequest -- account for one finished request made by a thread
+func recordRequest(threadNum int, elapsed time.Duration) {
+	atomic.AddInt64(&requestNanos, int64(elapsed))
+	atomic.AddInt64(&opsDone, 1)
+	if wireBytes {
+		atomic.AddInt64(&wireRequests, 1)
+	}
+	latencies.record(threadNum, elapsed)
+}
+
+// percentile -- return the latency below which the fraction p of the sorted samples fall
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	n := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if n < 0 {
+		n = 0
+	}
+	return sorted[n]
+}
+
+// Requests needed before p99.9 and p99.99 are more than the slowest request: below 1000 samples p99.9
+// is the maximum, and below 10000 so is p99.99
+const minSamplesP999, minSamplesP9999 = 1000, 10000
+
+// tailWarning -- say which of the extreme percentiles a phase made too few requests to estimate
+func tailWarning(samples int) string {
+	switch {
+	case samples == 0:
+		return ""
+	case samples < minSamplesP999:
+		return fmt.Sprintf("only %d requests, p99.9 needs %d and p99.99 %d", samples, minSamplesP999, minSamplesP9999)
+	case samples < minSamplesP9999:
+		return fmt.Sprintf("only %d requests, p99.99 needs %d", samples, minSamplesP9999)
+	}
+	return ""
+}
+
+// setLatencies -- fill in the latency percentiles of a phase, in milliseconds
+func setLatencies(l *logMessage, set latencySet) {
+	all := set.sorted()
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	l.LatencyP50 = ms(percentile(all, 0.50))
+	l.LatencyP90 = ms(percentile(all, 0.90))
+	l.LatencyP99 = ms(percentile(all, 0.99))
+	l.LatencyP999 = ms(percentile(all, 0.999))
+	l.LatencyP9999 = ms(percentile(all, 0.9999))
+	l.LatencyMax = ms(percentile(all, 1))
+	l.TailWarning = tailWarning(len(all))
+	if trimPercent > 0 && len(all) > 0 {
+		l.Trimmed = newTrimmedStats(all, l)
+	}
+}
+
+// perThread -- set by -perthread to report operations/sec divided by the thread count
+var perThread bool
+
+// setPerThread -- normalize a phase's operations/sec by its threads, to compare runs with different -t
+func setPerThread(l *logMessage) {
+	if perThread && l.Threads > 0 {
+		l.PerThread = l.Operations / float64(l.Threads)
+	}
+}
+
+// trimPercent -- set by -trim to also report each phase without its slowest requests
+var trimPercent float64
+
+// trimmedStats -- a phase's results with the slowest -trim percent of requests left out
+type trimmedStats struct {
+	Percent     float64 `json:"percent"`
+	MeanLatency float64 `json:"meanLatency"`
+	TrimmedMean float64 `json:"trimmedMeanLatency"`
+	Operations  float64 `json:"operations"`
+	RawSpeed    uint64  `json:"rawSpeed,omitempty"`
+}
+
+// newTrimmedStats -- the trimmed mean latency, and the throughput the phase would have reached had every
+// request taken that long instead, with the same number of requests in flight
+func newTrimmedStats(sorted []time.Duration, l *logMessage) *trimmedStats {
+	keep := len(sorted) - int(float64(len(sorted))*trimPercent/100)
+	if keep < 1 {
+		keep = 1
+	}
+	mean := func(durations []time.Duration) float64 {
+		var sum time.Duration
+		for _, d := range durations {
+			sum += d
+		}
+		return float64(sum) / float64(len(durations)) / float64(time.Millisecond)
+	}
+	t := &trimmedStats{Percent: trimPercent, MeanLatency: mean(sorted), TrimmedMean: mean(sorted[:keep])}
+	scale := 1.0
+	if t.TrimmedMean > 0 {
+		scale = t.MeanLatency / t.TrimmedMean
+	}
+	t.Operations = l.Operations * scale
+	t.RawSpeed = uint64(float64(l.RawSpeed) * scale)
+	return t
+}
+
+func (t trimmedStats) String() string {
+	msg := fmt.Sprintf(", mean latency = %.1f ms; without the slowest %v%%: mean latency = %.1f ms, %.1f operations/sec",
+		t.MeanLatency, t.Percent, t.TrimmedMean, t.Operations)
+	if t.RawSpeed > 0 {
+		msg += fmt.Sprintf(", speed = %sB/sec", bytefmt.ByteSize(t.RawSpeed))
+	}
+	return msg
+}
+
+// resetPhaseStats -- clear the counters accumulated during a single phase run with count threads
+func resetPhaseStats(count int) {
+	phaseThreads = count
+	latencies = newLatencySet(count)
+	readLatencies = newLatencySet(count)
+	requestNanos = 0
+	opsDone = 0
+	bytesDone = 0
+	redirectCount = 0
+	retryCount = 0
+	errorCount = 0
+	rampErrors = 0
+	rampBytes, rampReadBytes = 0, 0
+	atomic.StoreInt32(&rampingUp, 0)
+	missingCount = 0
+	staleCount = 0
+	readBytes = 0
+	resetBreakdown()
+	signNanos = 0
+	signCount = 0
+	wireSent = 0
+	wireRequests = 0
+	wireReceived = 0
+	dialCount, dialWaitNanos, dialWaitMax = 0, 0, 0
+	connsOpened = 0
+	failFastSeen, failFastDenied = 0, 0
+	resetMix()
+	peakGoroutines = 0
+}
+
+// threadPool -- set by -pool to run the phase's threads on at most this many goroutines
+var threadPool int
+
+// peakGoroutines -- the most goroutines seen during a phase run with -pool
+var peakGoroutines int
+
+// connections -- set by -connections to allow at most this many requests in flight, whatever the threads and goroutines
+var connections int
+var connSlots chan struct{}
+
+// connsOpened -- the connections dialed during a phase
+var connsOpened int64
+
+// connStats -- how a phase's threads mapped onto goroutines and -connections
+type connStats struct {
+	Threads     int   `json:"threads"`
+	Goroutines  int   `json:"goroutines"`
+	Connections int   `json:"connections"`
+	Opened      int64 `json:"opened"`
+}
+
+func (c connStats) String() string {
+	return fmt.Sprintf(", %d threads on %d goroutines over %d connections (%d opened)",
+		c.Threads, c.Goroutines, c.Connections, c.Opened)
+}
+
+// setConnections -- report the phase's threads, goroutines and connections, when -connections is set
+func setConnections(l *logMessage) {
+	if connSlots == nil {
+		return
+	}
+	workers := phaseThreads
+	if threadPool > 0 && threadPool < workers {
+		workers = threadPool
+	}
+	in := connections
+	if workers < in {
+		// There are never more requests in flight than goroutines to send them
+		in = workers
+	}
+	l.Connections = &connStats{Threads: phaseThreads, Goroutines: workers, Connections: in, Opened: atomic.LoadInt64(&connsOpened)}
+}
+
+// Set by -thinktime to pause each thread for between thinkMin and thinkMax after every request
+var thinkMin, thinkMax time.Duration
+
+// parseThinkTime -- parse a duration such as 10ms, or a range such as 5ms-20ms to pick from at random
+func parseThinkTime(arg string) error {
+	parts := strings.SplitN(arg, "-", 2)
+	var err error
+	if thinkMin, err = time.ParseDuration(strings.TrimSpace(parts[0])); err != nil {
+		return err
+	}
+	thinkMax = thinkMin
+	if len(parts) == 2 {
+		if thinkMax, err = time.ParseDuration(strings.TrimSpace(parts[1])); err != nil {
+			return err
+		}
+	}
+	if thinkMin < 0 || thinkMax < thinkMin {
+		return fmt.Errorf("expected a duration of 0 or more, or a range from a shorter to a longer one")
+	}
+	return nil
+}
+
+// thinkTime -- how long a thread pauses after a request, picked from the -thinktime range
+func thinkTime() time.Duration {
+	if thinkMax == thinkMin {
+		return thinkMin
+	}
+	return thinkMin + time.Duration(rand.Int63n(int64(thinkMax-thinkMin)+1))
+}
+
+// think -- pause a thread for its think time, cut short when the phase ends
+func think() {
+	t := time.NewTimer(thinkTime())
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-phaseCtx.Done():
+	}
+}
+
+// startThreads -- run every thread of the phase, each calling run for one request at a time until it
+// returns false, spreading the starts over rampSecs. With -pool the threads wait their turn in a queue
+// for one of the pool's goroutines, so only that many requests are in flight, and with -connections each
+// request waits for one of that many slots. With -thinktime each thread pauses after every request, a pooled
+// thread rejoining the queue only once its pause is over so it doesn't hold up a goroutine.
+func startThreads(run func(int) bool, rampSecs int) {
+	if rampSecs > 0 {
+		atomic.StoreInt32(&rampingUp, 1)
+	}
+	step := func(n int) bool {
+		if connSlots != nil {
+			select {
+			case connSlots <- struct{}{}:
+			case <-phaseCtx.Done():
+				return false
+			}
+			defer func() { <-connSlots }()
+		}
+		atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		return run(n)
+	}
+	workers := phaseThreads
+	var queue chan int
+	remaining := int64(phaseThreads)
+	if threadPool > 0 && threadPool < phaseThreads {
+		workers = threadPool
+		queue = make(chan int, phaseThreads)
+		for n := 1; n <= phaseThreads; n++ {
+			queue <- n
+		}
+	}
+	wg.Add(workers)
+	for n := 1; n <= workers; n++ {
+		if rampSecs > 0 && n > 1 {
+			time.Sleep(time.Second * time.Duration(rampSecs) / time.Duration(workers))
+		}
+		if queue == nil {
+			go func(n int) {
+				for phaseCtx.Err() == nil && step(n) {
+					if thinkMax > 0 {
+						think()
+					}
+				}
+				// One less thread
+				wg.Done()
+			}(n)
+			continue
+		}
+		go func() {
+			for n := range queue {
+				if phaseCtx.Err() == nil && step(n) {
+					if thinkMax > 0 {
+						// The thread still counts as remaining, so the queue stays open for it
+						n := n
+						time.AfterFunc(thinkTime(), func() { queue <- n })
+						continue
+					}
+					queue <- n
+				} else if atomic.AddInt64(&remaining, -1) == 0 {
+					close(queue)
+				}
+			}
+			// One less worker
+			wg.Done()
+		}()
+	}
+}
+
+// waitThreads -- wait for the phase's threads to finish, sampling the goroutine count under -pool
+func waitThreads() {
+	if threadPool == 0 {
+		wg.Wait()
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		if n := runtime.NumGoroutine(); n > peakGoroutines {
+			peakGoroutines = n
+		}
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// rampedUp -- once all threads are running, return the new start time and the operations to discount
+func rampedUp(starttime time.Time, counter *int64) (time.Time, int64) {
+	if rampupSecs <= 0 {
+		return starttime, 0
+	}
+	atomic.StoreInt64(&requestNanos, 0)
+	atomic.StoreInt64(&signNanos, 0)
+	atomic.StoreInt64(&signCount, 0)
+	atomic.StoreInt64(&wireSent, 0)
+	atomic.StoreInt64(&wireRequests, 0)
+	atomic.StoreInt64(&wireReceived, 0)
+	resetMix()
+	atomic.StoreInt64(&rampErrors, atomic.LoadInt64(&errorCount))
+	atomic.StoreInt64(&rampBytes, atomic.LoadInt64(&bytesDone))
+	atomic.StoreInt64(&rampReadBytes, atomic.LoadInt64(&readBytes))
+	atomic.StoreInt32(&rampingUp, 0)
+	return time.Now(), atomic.LoadInt64(counter)
+}
+
+// rootCtx -- cancelled by an interrupt, which stops the run once the running phase has been reported
+var rootCtx = context.Background()
+
+// phaseCtx -- the context of the running phase's requests, outside a phase the same as rootCtx
+var phaseCtx = context.Background()
+
+// startPhaseContext -- set phaseCtx for a phase, returning the function to call once its threads are done
+// GETs are abandoned at the phase's endtime, but not uploads and deletes: an upload cut off would leave a gap
+// in the objects the later phases expect, and deletes run until all the objects are gone.
+func startPhaseContext(cutoff bool) func() {
+	if !cutoff {
+		phaseCtx = rootCtx
+		return func() {}
+	}
+	ctx, cancel := context.WithDeadline(rootCtx, endtime)
+	phaseCtx = ctx
+	return func() {
+		cancel()
+		phaseCtx = rootCtx
+	}
+}
+
+// handleInterrupts -- cancel rootCtx on Ctrl-C or SIGTERM, a second one exits straight away
+func handleInterrupts() {
+	ctx, cancel := context.WithCancel(context.Background())
+	rootCtx, phaseCtx = ctx, ctx
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupts
+		signal.Stop(interrupts)
+		fmt.Fprintln(os.Stderr, "Interrupted, stopping after this phase (interrupt again to exit now)")
+		cancel()
+	}()
+}
+
+// checkInterrupted -- once a phase has been reported, end the run if it was interrupted
+func checkInterrupted() {
+	if rootCtx.Err() == nil {
+		return
+	}
+	fatal("Benchmark interrupted, objects may be left in the bucket")
+}
+
+// newRequest -- build a request for the endpoint, applying the -hosthdr override
+func newRequest(method, url string, body io.Reader) *http.Request {
+	// http.NewRequestWithContext needs Go 1.13
+	req, _ := http.NewRequest(method, url, body)
+	req = req.WithContext(phaseCtx)
+	if hostHeader != "" {
+		// Only the Host header changes, the connection still goes to the -u endpoint.
+		// The resource signed by setSignature is the path, so the signature is unaffected.
+		req.Host = hostHeader
+	}
+	if traceBreakdown {
+		req = traceRequest(req)
+	}
+	return req
+}
+
+// mix64 -- the splitmix64 step, for per-object values that can be derived again from the object number
+func mix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// objectSizeFor -- the size of an object, spread by -sizejitter around -z
+// The spread is derived from the object number so a later GET can check the same size
+func objectSizeFor(objnum int64) uint64 {
+	if mixClasses != nil {
+		return mixClasses[mixClassOf(objnum)].size
+	}
+	if sizeJitter == 0 || objectSize == 0 {
+		// Nothing to spread, and zero-byte objects stay empty
+		return objectSize
+	}
+	// Map to [-1, 1)
+	spread := float64(mix64(uint64(objnum))>>11)/(1<<52) - 1
+	size := uint64(math.Round(float64(objectSize) * (1 + spread*sizeJitter/100)))
+	if size == 0 {
+		size = 1
+	}
+	return size
+}
+
+// setChunked -- send the request body with chunked transfer encoding instead of a Content-Length
+func setChunked(req *http.Request) {
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+}
+
+// uploadObject -- PUT a single object, returning the request time and whether the backend accepted it
+func uploadObject(objnum int64) (time.Duration, bool) {
+	if sdkClient != nil {
+		return sdkUploadObject(objnum)
+	}
+	size := objectSizeFor(objnum)
+	var fileobj io.Reader
+	var contentMD5 string
+	if streamData {
+		fileobj = newStreamReader(objnum, size)
+	} else {
+		data := objectBytes(objnum, size)
+		fileobj = bytes.NewReader(data)
+		if lockMode != "" {
+			// S3 refuses uploads with a retention period that don't carry one
+			sum := md5.Sum(data)
+			contentMD5 = base64.StdEncoding.EncodeToString(sum[:])
+		}
+	}
+	prefix := objectURL(objnum, "")
+	req := newRequest(http.MethodPut, prefix, fileobj)
+	if streamData {
+		// Not a known reader type, so tell the transport the length and how to rewind
+		req.ContentLength = int64(size)
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(newStreamReader(objnum, size)), nil
+		}
+	}
+	if chunkedUpload {
+		setChunked(req)
+	} else {
+		req.Header.Set("Content-Length", strconv.FormatUint(size, 10))
+	}
+	if objectACL != "" {
+		req.Header.Set("X-Amz-Acl", objectACL)
+	}
+	if cacheControl != "" {
+		// Not part of the SigV2 string to sign
+		req.Header.Set("Cache-Control", cacheControl)
+	}
+	if contentDisposition != "" {
+		// Not signed either
+		req.Header.Set("Content-Disposition", contentDisposition)
+	}
+	if lockMode != "" {
+		// Signed as x-amz headers, and Content-MD5 as its own line of the string to sign
+		req.Header.Set("X-Amz-Object-Lock-Mode", lockMode)
+		req.Header.Set("X-Amz-Object-Lock-Retain-Until-Date", lockUntil.Format(time.RFC3339))
+		req.Header.Set("Content-MD5", contentMD5)
+	}
+	if expect100 {
+		req.Header.Set("Expect", "100-continue")
+	}
+	signRequest(req)
+	start := time.Now()
+	resp, err := doRequest(req)
+	if err != nil && phaseCtx.Err() != nil {
+		return time.Since(start), false
+	} else if err != nil {
+		fatalf("FATAL: Error uploading object %s: %v", prefix, err)
+	}
+	ok := resp.StatusCode == http.StatusOK
+	if ok {
+		atomic.AddInt64(&bytesDone, int64(size))
+		if objectVersions > 1 {
+			setVersionID(objnum, resp.Header.Get("X-Amz-Version-Id"))
+		}
+	} else {
+		atomic.AddInt64(&errorCount, 1)
+		logRequestError("Upload", prefix, resp)
+	}
+	drainBody(resp)
+	return time.Since(start), ok
+}
+
+// downloadObject -- GET a single object, returning the request time, the status, the bytes read
+// and, when verify is set, whether the body matched the data that was uploaded
+func downloadObject(objnum int64, verify bool) (time.Duration, int, int64, bool) {
+	if sdkClient != nil {
+		return sdkDownloadObject(objnum, verify)
+	}
+	var subresource string
+	if partNumber > 0 {
+		subresource = "partNumber=" + strconv.Itoa(partNumber)
+	}
+	prefix := objectURL(objnum, subresource)
+	if singleKey != "" {
+		prefix = keyURL(singleKey, 0, subresource)
+	}
+	req := newRequest(http.MethodGet, prefix, nil)
+	signRequest(req)
+	start := time.Now()
+	resp, err := doRequest(req)
+	if err != nil && phaseCtx.Err() != nil {
+		return time.Since(start), 0, 0, false
+	} else if err != nil {
+		fatalf("FATAL: Error downloading object %s: %v", prefix, err)
+	}
+	matched := false
+	body := &countingReader{r: resp.Body}
+	if resp.StatusCode != http.StatusOK {
+		logRequestError("Download", prefix, resp)
+	} else if verify {
+		matched = matchesObject(body, objnum)
+	}
+	if noDrain {
+		// Skip the payload, the connection is usually not reused after this
+		resp.Body.Close()
+		return time.Since(start), resp.StatusCode, body.n, matched
+	}
+	n := body.n + drainBody(resp)
+	return time.Since(start), resp.StatusCode, n, matched
+}
+
+// generations -- under -overwrite, how many times each thread's object has been written
+var generations []int64
+
+// objectBytes -- the data uploaded for an object, under -overwrite starting with its generation so each
+// write differs from the last
+func objectBytes(objnum int64, size uint64) []byte {
+	if !overwrite {
+		return objectData[:size]
+	}
+	var stamp [8]byte
+	binary.BigEndian.PutUint64(stamp[:], uint64(atomic.LoadInt64(&generations[objnum-1])))
+	data := make([]byte, size)
+	copy(data, objectData[:size])
+	copy(data, stamp[:])
+	return data
+}
+
+// countingReader -- counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// matchesObject -- compare a downloaded body with the data uploaded for the object
+func matchesObject(body io.Reader, objnum int64) bool {
+	size := objectSizeFor(objnum)
+	var expected io.Reader
+	if streamData {
+		expected = newStreamReader(objnum, size)
+	} else {
+		expected = bytes.NewReader(objectBytes(objnum, size))
+	}
+	want := make([]byte, 32*1024)
+	got := make([]byte, len(want))
+	for {
+		n, err := io.ReadFull(expected, want)
+		m, _ := io.ReadFull(body, got[:n])
+		if m != n || !bytes.Equal(want[:n], got[:n]) {
+			return false
+		}
+		if err != nil {
+			// Expected data is exhausted, the body must be too
+			extra, _ := body.Read(got[:1])
+			return extra == 0
+		}
+	}
+}
+
+// runUpload -- one PUT of an upload thread, false once the phase is over
+func runUpload(threadNum int) bool {
+	if !time.Now().Before(endtime) {
+		return false
+	}
+	objnum := atomic.AddInt64(&uploadCount, 1)
+	if maxObjects > 0 && objnum > maxObjects {
+		// Over the cap, give the number back and stop
+		atomic.AddInt64(&uploadCount, -1)
+		return false
+	}
+	if interleave {
+		objnum = interleavedObject(threadNum)
+	}
+	if bgDeleters > 0 {
+		atomic.StoreInt64(&uploading[threadNum-1], objnum)
+	}
+	if mixClasses != nil {
+		setMixClass(objnum, mixThreadClass(threadNum))
+	}
+	elapsed, ok := uploadObject(recycledObject(objnum))
+	if bgDeleters > 0 {
+		atomic.StoreInt64(&uploading[threadNum-1], 0)
+	}
+	if phaseCtx.Err() != nil {
+		return false
+	}
+	if ok {
+		mixRecord(objnum, int64(objectSizeFor(objnum)))
+	}
+	recordRequest(threadNum, elapsed)
+	return true
+}
+
+// interleave -- set by -interleave to number upload thread i's objects i, i+T, i+2T, ... for T threads, instead of
+// taking the next number from the counter all the threads share
+var interleave bool
+
+// Objects each upload thread has numbered under -interleave, and the upload thread count they are spaced by
+var interleaveCounts []int64
+var interleaveThreads int
+
+// interleaveEnds -- the running totals of interleaveCounts once the upload phase is over, for uploadedObject
+var interleaveEnds []int64
+
+// interleavedObject -- under -interleave, claim the next object number of an upload thread
+func interleavedObject(threadNum int) int64 {
+	k := atomic.AddInt64(&interleaveCounts[threadNum-1], 1) - 1
+	return int64(threadNum) + k*int64(interleaveThreads)
+}
+
+// setInterleaveEnds -- total up the objects each thread numbered, once they are final
+func setInterleaveEnds() {
+	interleaveEnds = make([]int64, len(interleaveCounts))
+	var sum int64
+	for i, count := range interleaveCounts {
+		sum += count
+		interleaveEnds[i] = sum
+	}
+}
+
+// uploadedObject -- the object number of the n-th object uploaded in the loop, counting from 1. That is n
+// itself, except under -interleave, where the threads' numbers leave gaps: the objects are counted a thread at a time.
+func uploadedObject(n int64) int64 {
+	if !interleave {
+		return n
+	}
+	t := sort.Search(len(interleaveEnds), func(i int) bool { return interleaveEnds[i] >= n })
+	if t > 0 {
+		n -= interleaveEnds[t-1]
+	}
+	return int64(t+1) + (n-1)*int64(interleaveThreads)
+}
+
+// bgDelete -- set by -bgdelete to the fraction of the upload threads that delete the oldest objects instead
+var bgDelete float64
+
+// bgDeleters -- how many of the upload phase's threads are deleting, the first ones
+var bgDeleters int
+
+// bgDeleted -- the objects the upload phase deleted, always the lowest numbers, so the survivors start after them
+var bgDeleted int64
+
+// uploading -- the object each upload thread is writing, 0 when none, so deletes never overtake an upload
+var uploading []int64
+
+// runBackgroundDelete -- one DELETE of the oldest object that has finished uploading, false once the phase is over
+func runBackgroundDelete(threadNum int) bool {
+	if !time.Now().Before(endtime) {
+		return false
+	}
+	next := atomic.LoadInt64(&bgDeleted) + 1
+	if next > uploadedBelow() {
+		// Caught up with the uploads
+		time.Sleep(10 * time.Millisecond)
+		return true
+	}
+	if !atomic.CompareAndSwapInt64(&bgDeleted, next-1, next) {
+		// Another thread took it
+		return true
+	}
+	elapsed := deleteObject(next)
+	if phaseCtx.Err() != nil {
+		return false
+	}
+	atomic.AddInt64(&requestNanos, int64(elapsed))
+	readLatencies.record(threadNum, elapsed)
+	return true
+}
+
+// uploadedBelow -- the highest object number below which every upload has finished
+func uploadedBelow() int64 {
+	below := atomic.LoadInt64(&uploadCount)
+	for i := range uploading {
+		if n := atomic.LoadInt64(&uploading[i]); n != 0 && n <= below {
+			below = n - 1
+		}
+	}
+	return below
+}
+
+// workingSet -- set by -workingset to keep uploading over the same this many objects, oldest first
+var workingSet int64
+
+// recycledObject -- the object an upload writes, under -workingset the one written longest ago once all exist
+func recycledObject(objnum int64) int64 {
+	if workingSet == 0 {
+		return objnum
+	}
+	return (objnum-1)%workingSet + 1
+}
+
+// uploadLimit -- which of -maxobjects and -d ended an upload phase, when both apply
+func uploadLimit() string {
+	if maxObjects == 0 || fillTo > 0 {
+		return ""
+	}
+	if uploadCount >= maxObjects {
+		return "object count"
+	}
+	return "duration"
+}
+
+// downloadKeyspace -- the number of objects GETs pick from, -objectcount or what this loop uploaded
+// and did not delete again, capped at -readset
+func downloadKeyspace() int64 {
+	keys := uploadCount - bgDeleted
+	if objectCount > 0 {
+		keys = objectCount
+	}
+	if readSet > 0 && keys > readSet {
+		keys = readSet
+	}
+	return keys
+}
+
+// sequentialRead -- set by -sequentialread to GET the objects in the order they were uploaded, wrapping around to
+// the first after the last unless readOnce is set too
+var sequentialRead, readOnce bool
+
+// readNext -- under -sequentialread, the reads handed out so far in the GET phase
+var readNext int64
+
+// runDownload -- one GET of a download thread, false once the phase is over
+func runDownload(threadNum int) bool {
+	keys := downloadKeyspace()
+	if keys == 0 || !time.Now().Before(endtime) {
+		return false
+	}
+	n := rand.Int63n(keys)
+	if sequentialRead {
+		n = atomic.AddInt64(&readNext, 1) - 1
+		if readOnce && n >= keys {
+			return false
+		}
+		n %= keys
+	}
+	atomic.AddInt64(&downloadCount, 1)
+	objnum := uploadedObject(n + 1 + bgDeleted)
+	elapsed, status, n, _ := downloadObject(objnum, false)
+	if phaseCtx.Err() != nil {
+		// Cut off by the deadline or an interrupt, it doesn't count
+		atomic.AddInt64(&downloadCount, -1)
+		return false
+	}
+	if status == http.StatusOK {
+		atomic.AddInt64(&bytesDone, n)
+		mixRecord(objnum, n)
+	} else {
+		atomic.AddInt64(&errorCount, 1)
+	}
+	recordRequest(threadNum, elapsed)
+	return true
+}
+
+// runAttributes -- fetch object metadata with GetObjectAttributes. The vendored SDK predates
+// that API, so the request goes through the same signed HTTP path as the other phases.
+func runAttributes(threadNum int) bool {
+	keys := downloadKeyspace()
+	if keys == 0 || !time.Now().Before(endtime) {
+		return false
+	}
+	atomic.AddInt64(&attributesCount, 1)
+	objnum := uploadedObject(rand.Int63n(keys) + 1 + bgDeleted)
+	prefix := objectURL(objnum, "attributes")
+	req := newRequest(http.MethodGet, prefix, nil)
+	req.Header.Set("X-Amz-Object-Attributes", "ETag,Checksum,ObjectParts,StorageClass,ObjectSize")
+	signRequest(req)
+	start := time.Now()
+	if resp, err := doRequest(req); phaseCtx.Err() != nil {
+		atomic.AddInt64(&attributesCount, -1)
+		return false
+	} else if err != nil {
+		fatalf("FATAL: Error fetching attributes of object %s: %v", prefix, err)
+	} else {
+		if resp.StatusCode != http.StatusOK {
+			atomic.AddInt64(&errorCount, 1)
+			logRequestError("GetObjectAttributes", prefix, resp)
+		}
+		drainBody(resp)
+	}
+	recordRequest(threadNum, time.Since(start))
+	return true
+}
+
+// listDelimiter -- set by -delimiter to benchmark directory-style listing, grouped by this delimiter, after the GET phase
+var listDelimiter string
+
+// Listings made in the LIST phase, and the keys and common prefixes they returned
+var listCount, listKeys, listPrefixes int64
+
+// listResult -- the parts of a ListObjectsV2 response the LIST phase counts
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+}
+
+// listPrefix -- a directory to list: that of a random uploaded object, at a random depth of its -keydepth
+// levels, so the listings spread over the tree as a file browser's would
+func listPrefix(objnum int64) string {
+	key := objectKey(objnum)
+	depth := rand.Intn(keyDepth + 1)
+	end := 0
+	for i := 0; i < depth; i++ {
+		next := strings.Index(key[end:], listDelimiter)
+		if next < 0 {
+			break
+		}
+		end += next + len(listDelimiter)
+	}
+	return key[:end]
+}
+
+// runList -- list one page of a directory with ListObjectsV2 and the -delimiter
+func runList(threadNum int) bool {
+	keys := downloadKeyspace()
+	if keys == 0 || !time.Now().Before(endtime) {
+		return false
+	}
+	atomic.AddInt64(&listCount, 1)
+	prefix := listPrefix(uploadedObject(rand.Int63n(keys) + 1 + bgDeleted))
+	query := []string{"list-type=2", "max-keys=1000", "delimiter=" + url.QueryEscape(listDelimiter)}
+	if prefix != "" {
+		query = append(query, "prefix="+url.QueryEscape(prefix))
+	}
+	listURL := bucketURL() + "/?" + strings.Join(query, "&")
+	req := newRequest(http.MethodGet, listURL, nil)
+	signRequest(req)
+	start := time.Now()
+	if resp, err := doRequest(req); phaseCtx.Err() != nil {
+		atomic.AddInt64(&listCount, -1)
+		return false
+	} else if err != nil {
+		fatalf("FATAL: Error listing %s: %v", listURL, err)
+	} else {
+		if resp.StatusCode != http.StatusOK {
+			atomic.AddInt64(&errorCount, 1)
+			logRequestError("ListObjectsV2", listURL, resp)
+		} else {
+			var result listResult
+			if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+				atomic.AddInt64(&errorCount, 1)
+			}
+			atomic.AddInt64(&listKeys, int64(len(result.Contents)))
+			atomic.AddInt64(&listPrefixes, int64(len(result.CommonPrefixes)))
+		}
+		drainBody(resp)
+	}
+	recordRequest(threadNum, time.Since(start))
+	return true
+}
+
+// runReadAfterWrite -- PUT each object and immediately GET it back
+func runReadAfterWrite(threadNum int) bool {
+	if !time.Now().Before(endtime) {
+		return false
+	}
+	objnum := atomic.AddInt64(&uploadCount, 1)
+	if maxObjects > 0 && objnum > maxObjects {
+		atomic.AddInt64(&uploadCount, -1)
+		return false
+	}
+	elapsed, ok := uploadObject(objnum)
+	if phaseCtx.Err() != nil {
+		return false
+	}
+	recordRequest(threadNum, elapsed)
+	if !ok {
+		return true
+	}
+	atomic.AddInt64(&downloadCount, 1)
+	elapsed, status, n, matched := downloadObject(objnum, verifyData)
+	if phaseCtx.Err() != nil {
+		atomic.AddInt64(&downloadCount, -1)
+		return false
+	}
+	atomic.AddInt64(&bytesDone, n)
+	atomic.AddInt64(&readBytes, n)
+	atomic.AddInt64(&requestNanos, int64(elapsed))
+	readLatencies.record(threadNum, elapsed)
+	switch {
+	case status == http.StatusNotFound:
+		atomic.AddInt64(&missingCount, 1)
+	case status != http.StatusOK:
+		atomic.AddInt64(&errorCount, 1)
+	case verifyData && !matched:
+		atomic.AddInt64(&staleCount, 1)
+	}
+	return true
+}
+
+// Overwrites done in the -overwrite phase, and the ones whose new data was read back with the total time that took
+var overwriteCount, convergedCount, convergeNanos int64
+
+// runOverwrite -- PUT a new generation of the thread's own object, then GET it until the new data comes back
+func runOverwrite(threadNum int) bool {
+	if !time.Now().Before(endtime) {
+		return false
+	}
+	objnum := int64(threadNum)
+	atomic.AddInt64(&generations[objnum-1], 1)
+	elapsed, ok := uploadObject(objnum)
+	if phaseCtx.Err() != nil {
+		return false
+	}
+	atomic.AddInt64(&overwriteCount, 1)
+	recordRequest(threadNum, elapsed)
+	if !ok {
+		return true
+	}
+	written := time.Now()
+	for time.Now().Before(endtime) {
+		start := time.Now()
+		elapsed, status, n, matched := downloadObject(objnum, true)
+		if phaseCtx.Err() != nil {
+			return false
+		}
+		atomic.AddInt64(&downloadCount, 1)
+		atomic.AddInt64(&bytesDone, n)
+		atomic.AddInt64(&readBytes, n)
+		atomic.AddInt64(&requestNanos, int64(elapsed))
+		readLatencies.record(threadNum, elapsed)
+		switch {
+		case status == http.StatusNotFound:
+			atomic.AddInt64(&missingCount, 1)
+		case status != http.StatusOK:
+			atomic.AddInt64(&errorCount, 1)
+		case !matched:
+			atomic.AddInt64(&staleCount, 1)
+		default:
+			atomic.AddInt64(&convergedCount, 1)
+			atomic.AddInt64(&convergeNanos, int64(start.Sub(written)))
+			return true
+		}
+	}
+	return true
+}
+
+func runDelete(threadNum int) bool {
+	n := atomic.AddInt64(&deleteCount, 1)
+	if n > uploadCount {
+		return false
+	}
+	objnum := uploadedObject(n)
+	if verifyDelete && !objectExists(objnum) {
+		if phaseCtx.Err() != nil {
+			return false
+		}
+		// A DELETE would succeed anyway, but there is nothing to delete
+		atomic.AddInt64(&missingCount, 1)
+		return true
+	}
+	elapsed := deleteObject(objnum)
+	if phaseCtx.Err() != nil {
+		return false
+	}
+	recordRequest(threadNum, elapsed)
+	return true
+}
+
+// objectExists -- HEAD an object for -verifydelete, anything but a 404 counts as existing
+func objectExists(objnum int64) bool {
+	if sdkClient != nil {
+		return sdkObjectExists(objnum)
+	}
+	prefix := objectURL(objnum, "")
+	req := newRequest(http.MethodHead, prefix, nil)
+	signRequest(req)
+	resp, err := doRequest(req)
+	if err != nil && phaseCtx.Err() != nil {
+		return false
+	} else if err != nil {
+		fatalf("FATAL: Error checking object %s: %v", prefix, err)
+	}
+	drainBody(resp)
+	return resp.StatusCode != http.StatusNotFound
+}
+
+// deleteObject -- DELETE a single object, returning the request time
+func deleteObject(objnum int64) time.Duration {
+	if sdkClient != nil {
+		return sdkDeleteObject(objnum)
+	}
+	prefix := objectURL(objnum, "")
+	req := newRequest(http.MethodDelete, prefix, nil)
+	signRequest(req)
+	start := time.Now()
+	resp, err := doRequest(req)
+	if err != nil && phaseCtx.Err() != nil {
+		return time.Since(start)
+	} else if err != nil {
+		fatalf("FATAL: Error deleting object %s: %v", prefix, err)
+	}
+	elapsed := time.Since(start)
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		atomic.AddInt64(&errorCount, 1)
+		logRequestError("Delete", prefix, resp)
+	}
+	drainBody(resp)
+	return elapsed
+}
+
+// fillObjects -- the number of objects it takes to reach -fillto
+func fillObjects() int64 {
+	if sizeJitter == 0 {
+		return int64((fillTo + objectSize - 1) / objectSize)
+	}
+	var n int64
+	for size := uint64(0); size < fillTo; {
+		n++
+		size += objectSizeFor(n)
+	}
+	return n
+}
+
+// datasetSize -- the total size of objects 1 to n
+func datasetSize(n int64) uint64 {
+	if sizeJitter == 0 {
+		return uint64(n) * objectSize
+	}
+	var size uint64
+	for objnum := int64(1); objnum <= n; objnum++ {
+		size += objectSizeFor(objnum)
+	}
+	return size
+}
+
+func runUploadPhase(loop int, total *summaryMessage) logMessage {
+	resetPhaseStats(threads)
+	starttime := time.Now()
+	endtime = starttime.Add(time.Second * time.Duration(rampupSecs+durationSecs))
+	if fillTo > 0 {
+		// Only the fill size stops the uploads
+		endtime = starttime.Add(365 * 24 * time.Hour)
+	}
+	stopSampler := startSampler(loop, http.MethodPut)
+	stopContext := startPhaseContext(false)
+	resumed := uploadCount
+	if interleave {
+		interleaveCounts, interleaveThreads = make([]int64, threads), threads
+	}
+	run := runUpload
+	if bgDelete > 0 {
+		// At least one thread of each kind, except in a single-threaded -calibrate loop
+		bgDeleters = int(math.Round(float64(threads) * bgDelete))
+		if bgDeleters < 1 {
+			bgDeleters = 1
+		}
+		if bgDeleters >= threads {
+			bgDeleters = threads - 1
+		}
+		uploading = make([]int64, threads)
+		run = func(threadNum int) bool {
+			if threadNum <= bgDeleters {
+				return runBackgroundDelete(threadNum)
+			}
+			return runUpload(threadNum)
+		}
+	}
+	startThreads(run, rampupSecs)
+	starttime, rampOps := rampedUp(starttime, &uploadCount)
+	if rampOps < resumed {
+		rampOps = resumed
+	}
+	rampDeletes := atomic.LoadInt64(&bgDeleted)
+	// Wait for it to finish
+	waitThreads()
+	stopContext()
+	stopSampler()
+	if interleave {
+		setInterleaveEnds()
+	}
+	uploadFinish = time.Now()
+	uploadTime := uploadFinish.Sub(starttime).Seconds()
+	total.Objects += uploadCount - resumed
+	total.BytesUploaded += uint64(bytesDone)
+	total.Errors += errorCount
+
+	measured := uploadCount - rampOps
+	bps := float64(bytesDone-rampBytes) / uploadTime
+	put := logMessage{
+		LogTime:     time.Now(),
+		Loop:        loop,
+		Method:      http.MethodPut,
+		Time:        uploadTime,
+		Objects:     uploadCount - resumed,
+		Speed:       bytefmt.ByteSize(uint64(bps)),
+		RawSpeed:    uint64(bps),
+		Operations:  (float64(measured) / uploadTime),
+		Utilization: utilization(uploadTime),
+		Redirects:   redirectCount,
+		Retries:     retryCount,
+		Goroutines:  peakGoroutines,
+		Errors:      errorCount - rampErrors,
+		Threads:     phaseThreads,
+		Size:        sizeLabel,
+		Endpoint:    endpointLabel,
+	}
+	put.StoppedBy = uploadLimit()
+	put.Resumed = resumed
+	if fillTo > 0 {
+		put.Filled = datasetSize(uploadCount)
+	}
+	if workingSet > 0 {
+		put.WorkingSet = workingSet
+		if uploadCount > workingSet {
+			// The later phases only see the objects that exist
+			put.Overwrites = uploadCount - workingSet
+			uploadCount = workingSet
+		}
+	}
+	if bgDeleters > 0 {
+		put.Threads = phaseThreads - bgDeleters
+	}
+	checkObjectCount(loop, &put)
+	setLatencies(&put, latencies)
+	setBreakdown(&put)
+	setSigning(&put)
+	setPerThread(&put)
+	setWire(&put)
+	setDialWait(&put)
+	setConnections(&put)
+	setAttempts(&put)
+	setMix(&put, true)
+	logit(put)
+	checkLatency(put)
+	checkThroughput(loop, http.MethodPut, bps)
+	if bgDeleters > 0 {
+		del := logMessage{
+			LogTime:     time.Now(),
+			Loop:        loop,
+			Method:      "BACKGROUND " + http.MethodDelete,
+			Time:        uploadTime,
+			Objects:     bgDeleted,
+			Operations:  (float64(bgDeleted-rampDeletes) / uploadTime),
+			Utilization: utilization(uploadTime),
+			Threads:     bgDeleters,
+			Size:        sizeLabel,
+			Endpoint:    endpointLabel,
+		}
+		setLatencies(&del, readLatencies)
+		setPerThread(&del)
+		logit(del)
+		checkLatency(del)
+		// The DELETE phase carries on from the oldest object still there
+		deleteCount = bgDeleted
+	}
+	return put
+}
+
+// noObjectsToRead -- warn that a reading phase is skipped when the loop has no objects for it, as when the
+// uploads were all cut short; its threads would only stop at once and report an empty phase
+func noObjectsToRead(loop int, method string) bool {
+	if downloadKeyspace() > 0 {
+		return false
+	}
+	log.Printf("WARNING: Loop %d: there are no uploaded objects to read, skipping the %s phase", loop, method)
+	return true
+}
+
+func runDownloadPhase(loop int, total *summaryMessage) logMessage {
+	if noObjectsToRead(loop, http.MethodGet) {
+		return logMessage{LogTime: time.Now(), Loop: loop, Method: http.MethodGet, Size: sizeLabel, Endpoint: endpointLabel}
+	}
+	resetPhaseStats(getThreads)
+	starttime := time.Now()
+	endtime = starttime.Add(time.Second * time.Duration(rampupSecs+durationSecs))
+	stopSampler := startSampler(loop, http.MethodGet)
+	stopContext := startPhaseContext(true)
+	readNext = 0
+	startThreads(runDownload, rampupSecs)
+	starttime, rampOps := rampedUp(starttime, &downloadCount)
+	// Wait for it to finish
+	waitThreads()
+	stopContext()
+	stopSampler()
+	downloadFinish = time.Now()
+	downloadTime := downloadFinish.Sub(starttime).Seconds()
+	total.BytesDownloaded += uint64(bytesDone)
+	total.Errors += errorCount
+
+	measured := downloadCount - rampOps
+	bps := float64(bytesDone-rampBytes) / downloadTime
+	get := logMessage{
+		LogTime:     time.Now(),
+		Loop:        loop,
+		Method:      http.MethodGet,
+		Time:        downloadTime,
+		Objects:     downloadCount,
+		Speed:       bytefmt.ByteSize(uint64(bps)),
+		RawSpeed:    uint64(bps),
+		Operations:  (float64(measured) / downloadTime),
+		Utilization: utilization(downloadTime),
+		Redirects:   redirectCount,
+		Retries:     retryCount,
+		Goroutines:  peakGoroutines,
+		Errors:      errorCount - rampErrors,
+		Threads:     phaseThreads,
+		Size:        sizeLabel,
+		Endpoint:    endpointLabel,
+	}
+	if readSet > 0 {
+		get.ReadSet = downloadKeyspace()
+	}
+	get.Sequential = sequentialRead
+	setLatencies(&get, latencies)
+	setBreakdown(&get)
+	setSigning(&get)
+	setPerThread(&get)
+	setWire(&get)
+	setDialWait(&get)
+	setConnections(&get)
+	setAttempts(&get)
+	setMix(&get, false)
+	logit(get)
+	checkLatency(get)
+	checkThroughput(loop, http.MethodGet, bps)
+	return get
+}
+
+// runReadAfterWritePhase -- run the -raw phase, reporting its PUTs and GETs separately
+func runReadAfterWritePhase(loop int, total *summaryMessage) (put, get logMessage) {
+	resetPhaseStats(threads)
+	starttime := time.Now()
+	endtime = starttime.Add(time.Second * time.Duration(rampupSecs+durationSecs))
+	stopSampler := startSampler(loop, "RAW")
+	stopContext := startPhaseContext(false)
+	startThreads(runReadAfterWrite, rampupSecs)
+	starttime, rampOps := rampedUp(starttime, &uploadCount)
+	// Wait for it to finish
+	waitThreads()
+	stopContext()
+	stopSampler()
+	uploadFinish = time.Now()
+	downloadFinish = uploadFinish
+	rawTime := uploadFinish.Sub(starttime).Seconds()
+	total.Objects += uploadCount
+	total.BytesUploaded += uint64(bytesDone - readBytes)
+	total.BytesDownloaded += uint64(readBytes)
+	total.Errors += errorCount
+
+	measured := uploadCount - rampOps
+	// bytesDone counts both directions, readBytes the GETs alone
+	bps := float64(bytesDone-readBytes-(rampBytes-rampReadBytes)) / rawTime
+	put = logMessage{
+		LogTime:     time.Now(),
+		Loop:        loop,
+		Method:      "RAW " + http.MethodPut,
+		Time:        rawTime,
+		Objects:     uploadCount,
+		Speed:       bytefmt.ByteSize(uint64(bps)),
+		RawSpeed:    uint64(bps),
+		Operations:  (float64(measured) / rawTime),
+		Utilization: utilization(rawTime),
+		Redirects:   redirectCount,
+		Retries:     retryCount,
+		Goroutines:  peakGoroutines,
+		Errors:      errorCount - rampErrors,
+		Threads:     phaseThreads,
+		Size:        sizeLabel,
+		Endpoint:    endpointLabel,
+	}
+	put.StoppedBy = uploadLimit()
+	setLatencies(&put, latencies)
+	setBreakdown(&put)
+	setSigning(&put)
+	setPerThread(&put)
+	setWire(&put)
+	setDialWait(&put)
+	setConnections(&put)
+	setAttempts(&put)
+	logit(put)
+	checkLatency(put)
+	checkThroughput(loop, http.MethodPut, bps)
+
+	measured = downloadCount
+	if measured > uploadCount-rampOps {
+		measured = uploadCount - rampOps
+	}
+	bps = float64(readBytes-rampReadBytes) / rawTime
+	get = logMessage{
+		LogTime:     time.Now(),
+		Loop:        loop,
+		Method:      "RAW " + http.MethodGet,
+		Time:        rawTime,
+		Objects:     downloadCount,
+		Speed:       bytefmt.ByteSize(uint64(bps)),
+		RawSpeed:    uint64(bps),
+		Operations:  (float64(measured) / rawTime),
+		Utilization: utilization(rawTime),
+		Threads:     phaseThreads,
+		Missing:     missingCount,
+		Stale:       staleCount,
+		Size:        sizeLabel,
+		Endpoint:    endpointLabel,
+	}
+	setLatencies(&get, readLatencies)
+	setBreakdown(&get)
+	setSigning(&get)
+	setPerThread(&get)
+	setWire(&get)
+	setDialWait(&get)
+	setConnections(&get)
+	setAttempts(&get)
+	logit(get)
+	checkLatency(get)
+	checkThroughput(loop, http.MethodGet, bps)
+	return put, get
+}
+
+// runOverwritePhase -- run the -overwrite phase, reporting its PUTs and GETs separately
+// Each thread keeps overwriting one object, which the DELETE phase removes afterwards
+func runOverwritePhase(loop int, total *summaryMessage) (put, get logMessage) {
+	resetPhaseStats(threads)
+	generations = make([]int64, threads)
+	overwriteCount, convergedCount, convergeNanos = 0, 0, 0
+	starttime := time.Now()
+	endtime = starttime.Add(time.Second * time.Duration(rampupSecs+durationSecs))
+	stopSampler := startSampler(loop, "OVERWRITE")
+	stopContext := startPhaseContext(false)
+	startThreads(runOverwrite, rampupSecs)
+	starttime, rampOps := rampedUp(starttime, &overwriteCount)
+	// Wait for it to finish
+	waitThreads()
+	stopContext()
+	stopSampler()
+	uploadFinish = time.Now()
+	downloadFinish = uploadFinish
+	overwriteTime := uploadFinish.Sub(starttime).Seconds()
+	uploadCount = int64(threads)
+	total.Objects += uploadCount
+	total.BytesUploaded += uint64(bytesDone - readBytes)
+	total.BytesDownloaded += uint64(readBytes)
+	total.Errors += errorCount
+
+	measured := overwriteCount - rampOps
+	bps := float64(bytesDone-readBytes-(rampBytes-rampReadBytes)) / overwriteTime
+	put = logMessage{
+		LogTime:     time.Now(),
+		Loop:        loop,
+		Method:      "OVERWRITE " + http.MethodPut,
+		Time:        overwriteTime,
+		Objects:     overwriteCount,
+		Speed:       bytefmt.ByteSize(uint64(bps)),
+		RawSpeed:    uint64(bps),
+		Operations:  (float64(measured) / overwriteTime),
+		Utilization: utilization(overwriteTime),
+		Redirects:   redirectCount,
+		Retries:     retryCount,
+		Goroutines:  peakGoroutines,
+		Errors:      errorCount - rampErrors,
+		Threads:     phaseThreads,
+		Size:        sizeLabel,
+		Endpoint:    endpointLabel,
+	}
+	setLatencies(&put, latencies)
+	setBreakdown(&put)
+	setSigning(&put)
+	setPerThread(&put)
+	setWire(&put)
+	setDialWait(&put)
+	setConnections(&put)
+	setAttempts(&put)
+	logit(put)
+	checkLatency(put)
+	checkThroughput(loop, http.MethodPut, bps)
+
+	bps = float64(readBytes-rampReadBytes) / overwriteTime
+	get = logMessage{
+		LogTime:     time.Now(),
+		Loop:        loop,
+		Method:      "OVERWRITE " + http.MethodGet,
+		Time:        overwriteTime,
+		Objects:     downloadCount,
+		Speed:       bytefmt.ByteSize(uint64(bps)),
+		RawSpeed:    uint64(bps),
+		Operations:  (float64(downloadCount) / overwriteTime),
+		Utilization: utilization(overwriteTime),
+		Threads:     phaseThreads,
+		Missing:     missingCount,
+		Stale:       staleCount,
+		Size:        sizeLabel,
+		Endpoint:    endpointLabel,
+	}
+	if downloadCount > 0 {
+		get.StaleShare = float64(staleCount+missingCount) / float64(downloadCount) * 100
+	}
+	if convergedCount > 0 {
+		ms := float64(convergeNanos) / float64(convergedCount) / float64(time.Millisecond)
+		get.Convergence = &ms
+	}
+	setLatencies(&get, readLatencies)
+	setBreakdown(&get)
+	setSigning(&get)
+	setPerThread(&get)
+	setWire(&get)
+	setDialWait(&get)
+	setConnections(&get)
+	setAttempts(&get)
+	logit(get)
+	checkLatency(get)
+	checkThroughput(loop, http.MethodGet, bps)
+	return put, get
+}
+
+func runAttributesPhase(loop int, total *summaryMessage) logMessage {
+	if noObjectsToRead(loop, "ATTRIBUTES") {
+		return logMessage{LogTime: time.Now(), Loop: loop, Method: "ATTRIBUTES", Size: sizeLabel, Endpoint: endpointLabel}
+	}
+	resetPhaseStats(getThreads)
+	attributesCount = 0
+	starttime := time.Now()
+	endtime = starttime.Add(time.Second * time.Duration(rampupSecs+durationSecs))
+	stopSampler := startSampler(loop, "ATTRIBUTES")
+	stopContext := startPhaseContext(true)
+	startThreads(runAttributes, rampupSecs)
+	starttime, rampOps := rampedUp(starttime, &attributesCount)
+	// Wait for it to finish
+	waitThreads()
+	stopContext()
+	stopSampler()
+	attributesTime := time.Now().Sub(starttime).Seconds()
+	total.Errors += errorCount
+
+	attrs := logMessage{
+		LogTime:     time.Now(),
+		Loop:        loop,
+		Method:      "ATTRIBUTES",
+		Time:        attributesTime,
+		Operations:  (float64(attributesCount-rampOps) / attributesTime),
+		Utilization: utilization(attributesTime),
+		Redirects:   redirectCount,
+		Retries:     retryCount,
+		Goroutines:  peakGoroutines,
+		Errors:      errorCount - rampErrors,
+		Threads:     phaseThreads,
+		Size:        sizeLabel,
+		Endpoint:    endpointLabel,
+	}
+	setLatencies(&attrs, latencies)
+	setBreakdown(&attrs)
+	setSigning(&attrs)
+	setPerThread(&attrs)
+	setWire(&attrs)
+	setDialWait(&attrs)
+	setConnections(&attrs)
+	setAttempts(&attrs)
+	logit(attrs)
+	checkLatency(attrs)
+	return attrs
+}
+
+func runListPhase(loop int, total *summaryMessage) logMessage {
+	if noObjectsToRead(loop, "LIST") {
+		return logMessage{LogTime: time.Now(), Loop: loop, Method: "LIST", Size: sizeLabel, Endpoint: endpointLabel}
+	}
+	resetPhaseStats(getThreads)
+	listCount, listKeys, listPrefixes = 0, 0, 0
+	starttime := time.Now()
+	endtime = starttime.Add(time.Second * time.Duration(rampupSecs+durationSecs))
+	stopSampler := startSampler(loop, "LIST")
+	stopContext := startPhaseContext(true)
+	startThreads(runList, rampupSecs)
+	starttime, rampOps := rampedUp(starttime, &listCount)
+	rampKeys, rampPrefixes := atomic.LoadInt64(&listKeys), atomic.LoadInt64(&listPrefixes)
+	// Wait for it to finish
+	waitThreads()
+	stopContext()
+	stopSampler()
+	listTime := time.Now().Sub(starttime).Seconds()
+	total.Errors += errorCount
+
+	list := logMessage{
+		LogTime:     time.Now(),
+		Loop:        loop,
+		Method:      "LIST",
+		Time:        listTime,
+		Objects:     listCount - rampOps,
+		Operations:  (float64(listCount-rampOps) / listTime),
+		Utilization: utilization(listTime),
+		Redirects:   redirectCount,
+		Retries:     retryCount,
+		Goroutines:  peakGoroutines,
+		Errors:      errorCount - rampErrors,
+		Threads:     phaseThreads,
+		Size:        sizeLabel,
+		Endpoint:    endpointLabel,
+		List: &listStats{
+			Delimiter: listDelimiter,
+			Keys:      float64(listKeys-rampKeys) / listTime,
+			Prefixes:  float64(listPrefixes-rampPrefixes) / listTime,
+		},
+	}
+	setLatencies(&list, latencies)
+	setBreakdown(&list)
+	setSigning(&list)
+	setPerThread(&list)
+	setWire(&list)
+	setDialWait(&list)
+	setConnections(&list)
+	setAttempts(&list)
+	logit(list)
+	checkLatency(list)
+	return list
+}
+
+func runDeletePhase(loop int, total *summaryMessage) logMessage {
+	resetPhaseStats(deleteThreads)
+	starttime := time.Now()
+	endtime = starttime.Add(time.Second * time.Duration(durationSecs))
+	stopSampler := startSampler(loop, http.MethodDelete)
+	stopContext := startPhaseContext(false)
+	// Deletes run until the objects are gone, so there is nothing to gain from a ramp
+	startThreads(runDelete, 0)
+
+	// Wait for it to finish
+	waitThreads()
+	stopContext()
+	stopSampler()
+	deleteFinish = time.Now()
+	deleteTime := deleteFinish.Sub(starttime).Seconds()
+	total.Errors += errorCount
+
+	del := logMessage{
+		LogTime:     time.Now(),
+		Loop:        loop,
+		Method:      http.MethodDelete,
+		Time:        deleteTime,
+		Operations:  (float64(uploadCount-bgDeleted-missingCount) / deleteTime),
+		Utilization: utilization(deleteTime),
+		Redirects:   redirectCount,
+		Retries:     retryCount,
+		Goroutines:  peakGoroutines,
+		Errors:      errorCount,
+		Missing:     missingCount,
+		Threads:     phaseThreads,
+		Size:        sizeLabel,
+		Endpoint:    endpointLabel,
+	}
+	setLatencies(&del, latencies)
+	setBreakdown(&del)
+	setSigning(&del)
+	setPerThread(&del)
+	setWire(&del)
+	setDialWait(&del)
+	setConnections(&del)
+	setAttempts(&del)
+	logit(del)
+	checkLatency(del)
+	return del
+}
+
+// runLoop -- run the PUT, GET and DELETE phases once, adding to the run totals
+func runLoop(loop int, total *summaryMessage) (put, get, del logMessage) {
+	// Only the first loop carries on from a -resume
+	uploadCount = resumeCount
+	resumeCount = 0
+	downloadCount = 0
+	deleteCount = 0
+	bgDeleted = 0
+	versionIDs = map[int64]string{}
+	if readAfterWrite {
+		put, get = runReadAfterWritePhase(loop, total)
+		checkInterrupted()
+	} else if overwrite {
+		put, get = runOverwritePhase(loop, total)
+		checkInterrupted()
+	} else {
+		put = runUploadPhase(loop, total)
+		checkInterrupted()
+		get = runDownloadPhase(loop, total)
+		checkInterrupted()
+	}
+	if objectAttributes {
+		runAttributesPhase(loop, total)
+		checkInterrupted()
+	}
+	if listDelimiter != "" {
+		runListPhase(loop, total)
+		checkInterrupted()
+	}
+	if mixedWeights != nil {
+		runMixedPhase(loop, total)
+		checkInterrupted()
+	}
+	del = runDeletePhase(loop, total)
+	checkInterrupted()
+	return put, get, del
+}
+
+// calibrate -- run a single-threaded loop 0 as the baseline for the scaling efficiency, outside the totals
+func calibrate() (put, get, del logMessage) {
+	savedThreads, savedGets, savedDeletes := threads, getThreads, deleteThreads
+	savedDuration, savedRampup, savedMin, savedP99 := durationSecs, rampupSecs, minThroughput, maxP99
+	threads, getThreads, deleteThreads = 1, 1, 1
+	durationSecs, rampupSecs, minThroughput, maxP99 = calibrateSecs, 0, 0, 0
+	var discard summaryMessage
+	put, get, del = runLoop(0, &discard)
+	threads, getThreads, deleteThreads = savedThreads, savedGets, savedDeletes
+	durationSecs, rampupSecs, minThroughput, maxP99 = savedDuration, savedRampup, savedMin, savedP99
+	return put, get, del
+}
+
+func main() {
+	// Parse command line
+	myflag := flag.NewFlagSet("myflag", flag.ExitOnError)
+	myflag.BoolVar(&jsonPrint, "j", false, "Log output in JSON format, same as -output json")
+	var outputArg string
+	myflag.StringVar(&outputArg, "output", "text", "Output format: text, json, or ndjson for one typed JSON record per line as each phase finishes")
+	var writeHeader bool
+	myflag.BoolVar(&writeHeader, "header", false, "Start the output, benchmark.log and the -timeseries file with the version, host and every flag value")
+	myflag.BoolVar(&compressLog, "compresslog", false, "Gzip benchmark.log and the -timeseries file, adding a .gz suffix")
+	myflag.BoolVar(&expect100, "expect100", false, "Send Expect: 100-continue on uploads and wait for the server before sending the body")
+	myflag.BoolVar(&readAfterWrite, "raw", false, "Read after write: GET every object immediately after its PUT instead of running separate phases")
+	myflag.BoolVar(&sequentialRead, "sequentialread", false, "GET the objects in the order they were uploaded, 1, 2, 3, ..., starting again from the first after the last, instead of at random")
+	myflag.BoolVar(&readOnce, "readonce", false, "With -sequentialread, end the GET phase once every object has been read instead of starting again from the first")
+	myflag.BoolVar(&verifyCount, "verifycount", false, "List the bucket after the PUT phase and warn if it doesn't hold as many objects as were uploaded")
+	myflag.BoolVar(&interleave, "interleave", false, "Number each upload thread's objects i, i+T, i+2T, ... for T threads, instead of from one counter shared by all the threads")
+	myflag.BoolVar(&overwrite, "overwrite", false, "Overwrite one object per thread and GET it after each PUT until the new data comes back, instead of running separate PUT and GET phases")
+	myflag.BoolVar(&verifyData, "verify", false, "With -raw, check that each GET returns the data just written")
+	myflag.BoolVar(&verifyDelete, "verifydelete", false, "HEAD each object before deleting it and only DELETE, and count, the ones that exist")
+	myflag.BoolVar(&objectAttributes, "attributes", false, "Benchmark GetObjectAttributes on the uploaded objects after the GET phase")
+	var opsArg string
+	myflag.StringVar(&opsArg, "ops", "", "Comma separated op=weight pairs of put, get, head, delete and copy, e.g. get=60,put=20,head=15,delete=5, to run a MIXED phase picking each request's operation by weight before the DELETE phase")
+	myflag.StringVar(&listDelimiter, "delimiter", "", "Benchmark directory-style ListObjectsV2 grouped by this delimiter, e.g. /, after the GET phase")
+	myflag.BoolVar(&noDrain, "nodrain", false, "Close GET responses without reading the body, to measure request rate rather than bandwidth")
+	myflag.BoolVar(&keepAlive, "keepalive", true, "Reuse connections for further requests, -keepalive=false for a new connection per request")
+	var dialConcurrency int
+	myflag.IntVar(&dialConcurrency, "dialconcurrency", 0, "Open at most this many connections at once, however many threads need one (0 for no limit)")
+	myflag.BoolVar(&noDelay, "nodelay", true, "Set TCP_NODELAY on connections, -nodelay=false to enable Nagle's algorithm")
+	myflag.BoolVar(&chunkedUpload, "chunked", false, "Upload with chunked transfer encoding instead of a Content-Length")
+	myflag.BoolVar(&showCompression, "compressratio", false, "Report how well gzip compresses a sample of the upload data")
+	myflag.BoolVar(&streamData, "stream", false, "Generate upload data on the fly instead of holding it in memory")
+	myflag.StringVar(&accessKey, "a", "Q3AM3UQ867SPQQA43P2F", "Access key")
+	myflag.StringVar(&secretKey, "s", "zuf+tfteSlswRu7BJ86wekitnifILbZam1KYY3TG", "Secret key")
+	var urlArg, regionArg string
+	myflag.StringVar(&urlArg, "u", "https://play.min.io", "URL for host with method prefix, or mock for an in-memory endpoint; comma separated to run against each in turn")
+	myflag.BoolVar(&accelerate, "accelerate", false, "Send requests to the bucket's S3 Transfer Acceleration endpoint, rewriting -u to "+accelerateHost)
+	myflag.StringVar(&regionArg, "region", "us-east-1", "Region for the SDK requests, or a comma separated list with one region per -u endpoint")
+	myflag.StringVar(&signingService, "service", "s3", "Service name in the SigV4 credential scope of the SDK requests, for gateways that check it")
+	myflag.StringVar(&runLabel, "label", "", "Label to tag this run's results with, e.g. before-upgrade")
+	myflag.StringVar(&bucket, "b", "s3-benchmark", "Bucket for testing")
+	myflag.StringVar(&cleanPrefix, "cleanprefix", "", "Only delete objects under this key prefix when cleaning the bucket at startup (e.g. Object-)")
+	myflag.Int64Var(&maxClean, "maxclean", 0, "Count the objects first and stop with an error, deleting none, if the startup cleanup finds more than this many (0 for no limit)")
+	myflag.IntVar(&cleanThreads, "cleanthreads", 16, "Number of DeleteObjects calls, of up to 1000 objects each, to run at once when cleaning the bucket")
+	myflag.BoolVar(&forceClean, "forceclean", false, "Delete every object the startup cleanup finds, even more than -maxclean")
+	myflag.IntVar(&bucketWaitSecs, "bucketwait", 30, "Seconds to wait for the bucket to answer a HEAD after creating it (0 not to check)")
+	var clientArg string
+	myflag.StringVar(&clientArg, "client", "raw", "Send PUTs, GETs, DELETEs and the MIXED phase's HEADs and copies with the minimal signed HTTP client (raw) or the AWS SDK (sdk)")
+	var anonymousArg string
+	myflag.StringVar(&anonymousArg, "anonymous", "", "Comma separated phases to send unsigned: put, get, delete, attributes or all")
+	myflag.Var(&extraQuery, "query", "Query parameter key=value to add to every object request, may be repeated")
+	myflag.StringVar(&hostHeader, "hosthdr", "", "Host header to send instead of the host in -u")
+	myflag.IntVar(&sdkRetries, "sdkretries", aws.UseServiceDefaultRetries, "Maximum retries for the SDK bucket setup and cleanup requests (-1 for the SDK default)")
+	myflag.IntVar(&durationSecs, "d", 10, "Duration of each test in seconds")
+	var threadsArg string
+	myflag.StringVar(&threadsArg, "t", "1", "Number of threads to run, or comma separated PUT,GET,DELETE thread counts")
+	var thinkArg string
+	myflag.StringVar(&thinkArg, "thinktime", "", "Pause each thread for this long after every request, e.g. 10ms, or for a random time in a range such as 5ms-20ms")
+	myflag.IntVar(&threadPool, "pool", 0, "Run the threads on at most this many goroutines, taking turns one request at a time (0 for one per thread)")
+	myflag.IntVar(&connections, "connections", 0, "Allow at most this many requests in flight at once, whatever the number of threads and goroutines (0 for no limit)")
+	myflag.IntVar(&deleteThreads, "deletethreads", 0, "Number of threads to run the DELETE phase with (defaults to -t)")
+	myflag.IntVar(&calibrateSecs, "calibrate", 0, "Seconds to run a single-threaded loop first and report the thread scaling efficiency against (0 to skip)")
+	myflag.IntVar(&loops, "l", 1, "Number of times to repeat test")
+	myflag.IntVar(&rampupSecs, "rampup", 0, "Seconds over which to stagger PUT and GET thread starts, excluded from the measured time, latencies and errors")
+	var sizeArg string
+	myflag.StringVar(&sizeArg, "z", "1M", "Size of objects in bytes with postfix K, M, and G, 0 for empty objects")
+	myflag.Float64Var(&sizeJitter, "sizejitter", 0, "Spread object sizes randomly by up to this percentage either side of -z")
+	var sweepArg string
+	myflag.StringVar(&sweepArg, "zsweep", "", "Comma separated list of object sizes to run the benchmark with in turn, overrides -z")
+	var mixSizesArg string
+	myflag.StringVar(&mixSizesArg, "mixsizes", "", "Comma separated size:percent pairs, e.g. 4K:50,1M:50, to split the threads between uploading objects of those sizes at once, overrides -z")
+	myflag.StringVar(&singleKey, "singlekey", "", "Key of an existing object for every GET to read, instead of the uploaded objects")
+	myflag.IntVar(&partNumber, "partnumber", 0, "GET only this part of each object with the partNumber parameter (objects uploaded here have a single part)")
+	myflag.Int64Var(&objectCount, "objectcount", 0, "Number of existing objects GETs pick from (defaults to the objects uploaded in the loop)")
+	var resume bool
+	myflag.BoolVar(&resume, "resume", false, "Keep the bucket's objects and continue uploading after the highest object number found")
+	myflag.Int64Var(&readSet, "readset", 0, "Maximum number of distinct objects GETs pick from, the first ones uploaded (0 for all)")
+	myflag.Float64Var(&bgDelete, "bgdelete", 0, "Fraction of the upload threads, e.g. 0.25, to delete the oldest uploaded objects during the PUT phase (0 for none)")
+	myflag.Int64Var(&workingSet, "workingset", 0, "Upload over a fixed set of this many objects, overwriting the oldest once all exist, to bound the storage used (0 for no limit)")
+	myflag.Int64Var(&maxObjects, "maxobjects", 0, "Maximum number of objects to upload per loop, uploads stop at this or -d, whichever comes first (0 for no limit)")
+	myflag.Int64Var(&maxObjects, "n", 0, "Short for -maxobjects")
+	var fillToArg string
+	myflag.StringVar(&fillToArg, "fillto", "", "Upload until the bucket holds this many bytes, with postfix K, M, and G, instead of for -d seconds")
+	var minThroughputArg string
+	myflag.StringVar(&minThroughputArg, "minthroughput", "", "Exit with an error if PUT or GET speed in bytes/sec falls below this, with postfix K, M, and G")
+	myflag.BoolVar(&showBanner, "banner", false, "Finish with a PASS/FAIL banner of the threshold checks and headline results, running on after a failed check")
+	myflag.BoolVar(&showSparkline, "sparkline", false, "Show each second's throughput as a sparkline while a phase runs (plain lines when not a terminal)")
+	myflag.BoolVar(&perThread, "perthread", false, "Also report each phase's operations/sec divided by its thread count")
+	myflag.Float64Var(&trimPercent, "trim", 0, "Also report each phase's mean latency and throughput without this percentage of the slowest requests")
+	myflag.Float64Var(&maxP99, "p99-max", 0, "Exit with an error if any phase's p99 latency in milliseconds is above this (0 for no limit)")
+	myflag.StringVar(&dateStyle, "datestyle", "amz", "Date signed requests with the X-Amz-Date header (amz) or the standard Date header (date), for endpoints that only accept one")
+	myflag.BoolVar(&failFast, "failfast", false, "Stop at once, printing what was signed, if the first requests are all refused with 403 Forbidden")
+	myflag.BoolVar(&profileSigning, "profilesigning", false, "Time request signing separately and report its cost per phase")
+	myflag.BoolVar(&wireBytes, "wirebytes", false, "Count the bytes sent and received on the connections and report the wire throughput and average request and response sizes next to the object throughput")
+	myflag.BoolVar(&traceBreakdown, "breakdown", false, "Report the average time requests spend in DNS, connect, TLS and waiting for the first byte")
+	var timeseriesPath string
+	myflag.StringVar(&tracePath, "trace", "", "Write a record of every request to this file, NDJSON if it ends in .ndjson or .jsonl and CSV otherwise")
+	myflag.StringVar(&metricsAddr, "metricsaddr", "", "Serve live metrics of the running phase on /metrics at this address, e.g. :9100")
+	myflag.StringVar(&metricsFile, "metricsfile", "", "Write the phase results and run totals to this file in OpenMetrics text format, e.g. for a node exporter textfile collector")
+	myflag.StringVar(&timeseriesPath, "timeseries", "", "Write per-second throughput samples to this CSV file")
+	myflag.IntVar(&objectVersions, "versions", 0, "Upload this many versions of each key and address GETs and DELETEs by versionId (needs a versioned bucket)")
+	myflag.StringVar(&keyFormat, "keyformat", "seq", "Object key format: seq for Object-N, or random or uuid for keys derived from N and -seed")
+	myflag.Int64Var(&keySeed, "seed", 0, "Seed for the random and uuid key formats, the same seed gives the same keys")
+	myflag.IntVar(&padWidth, "padwidth", 0, "Zero-pad the object number in Object-N keys to this many digits (e.g. Object-0000000005), so keys list in numeric order")
+	myflag.IntVar(&keyDepth, "keydepth", 0, "Number of pseudo-random directory levels to put object keys under (e.g. 3f/a0/Object-5)")
+	myflag.StringVar(&objectSuffix, "suffix", "", "Suffix appended to object keys (e.g. .bin)")
+	var sndBufArg, rcvBufArg string
+	myflag.StringVar(&sndBufArg, "sndbuf", "", "Socket send buffer size, with postfix K, M, and G (defaults to the system setting)")
+	myflag.StringVar(&rcvBufArg, "rcvbuf", "", "Socket receive buffer size, with postfix K, M, and G (defaults to the system setting)")
+	myflag.StringVar(&objectACL, "acl", "", "Canned ACL to set on uploaded objects (e.g. public-read)")
+	myflag.StringVar(&cacheControl, "cachecontrol", "", "Cache-Control header to set on uploaded objects (e.g. max-age=3600)")
+	myflag.StringVar(&lockMode, "lockmode", "", "Object Lock mode to upload objects with, GOVERNANCE or COMPLIANCE, the bucket must have Object Lock enabled")
+	var lockUntilArg string
+	myflag.StringVar(&lockUntilArg, "lockuntil", "", "Retain objects uploaded with -lockmode until this RFC 3339 time, or for this long from the start, e.g. 24h")
+	myflag.StringVar(&contentDisposition, "disposition", "", "Content-Disposition header to set on uploaded objects (e.g. attachment)")
+	if err := myflag.Parse(os.Args[1:]); err != nil {
+		os.Exit(1)
+	}
+
+	switch outputArg {
+	case "text":
+	case "json":
+		jsonPrint = true
+	case "ndjson":
+		jsonPrint, ndjson = true, true
+	default:
+		fatalf("Invalid -output argument %q: expected text, json or ndjson", outputArg)
+	}
+
+	// Hello
+	if !jsonPrint {
+		fmt.Println("S3 benchmark program " + version)
+	}
+
+	// Check the arguments
+	if accessKey == "" {
+		fatal("Missing argument -a for access key.")
+	}
+	if secretKey == "" {
+		fatal("Missing argument -s for secret key.")
+	}
+	if expect100 {
+		// Without a timeout the transport sends the body without waiting
+		HTTPTransport.(*http.Transport).ExpectContinueTimeout = time.Second
+	}
+	var counts []int
+	for _, arg := range strings.Split(threadsArg, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(arg))
+		if err != nil || n <= 0 {
+			fatalf("Invalid -t argument %q: thread counts must be positive integers", threadsArg)
+		}
+		counts = append(counts, n)
+	}
+	if len(counts) > 3 {
+		fatalf("Invalid -t argument %q: expected at most PUT,GET,DELETE thread counts", threadsArg)
+	}
+	// Phases without their own count use the first one
+	for len(counts) < 3 {
+		counts = append(counts, counts[0])
+	}
+	threads, getThreads = counts[0], counts[1]
+	if deleteThreads <= 0 {
+		deleteThreads = counts[2]
+	}
+	switch clientArg {
+	case "raw":
+	case "sdk":
+		for flagName, set := range map[string]bool{
+			"stream": streamData, "chunked": chunkedUpload, "anonymous": anonymousArg != "",
+			"hosthdr": hostHeader != "", "versions": objectVersions > 1, "partnumber": partNumber > 0,
+			"query": len(extraQuery) > 0, "failfast": failFast, "datestyle": dateStyle != "amz",
+		} {
+			if set {
+				fatalf("-%s is only supported with -client raw", flagName)
+			}
+		}
+	default:
+		fatalf("Invalid -client argument %q: expected raw or sdk", clientArg)
+	}
+	for _, phase := range strings.Split(anonymousArg, ",") {
+		switch phase = strings.ToLower(strings.TrimSpace(phase)); phase {
+		case "":
+		case "put", "get", "delete", "attributes":
+			anonymousPhases[phase] = true
+		case "all":
+			for _, p := range []string{"put", "get", "delete", "attributes"} {
+				anonymousPhases[p] = true
+			}
+		default:
+			fatalf("Invalid -anonymous argument %q: expected put, get, delete, attributes or all", anonymousArg)
+		}
+	}
+	if showBanner && jsonPrint {
+		fatal("-banner cannot be combined with JSON output")
+	}
+	if showSparkline && jsonPrint {
+		fatal("-sparkline cannot be combined with JSON output")
+	}
+	if noDrain && verifyData {
+		fatal("-nodrain cannot be combined with -verify")
+	}
+	if singleKey != "" && (readAfterWrite || objectVersions > 1 || resume) {
+		fatal("-singlekey cannot be combined with -raw, -versions or -resume")
+	}
+	if resume && (readAfterWrite || calibrateSecs > 0) {
+		// The calibration loop would delete the resumed objects
+		fatal("-resume cannot be combined with -raw or -calibrate")
+	}
+	if lockMode != "" || lockUntilArg != "" {
+		lockMode = strings.ToUpper(lockMode)
+		if lockMode != "GOVERNANCE" && lockMode != "COMPLIANCE" {
+			fatalf("Invalid -lockmode argument %q: expected GOVERNANCE or COMPLIANCE", lockMode)
+		}
+		if d, err := time.ParseDuration(lockUntilArg); err == nil && d > 0 {
+			lockUntil = time.Now().Add(d).UTC().Truncate(time.Second)
+		} else if lockUntil, err = time.Parse(time.RFC3339, lockUntilArg); err != nil || !lockUntil.After(time.Now()) {
+			fatalf("Invalid -lockuntil argument %q: expected a future RFC 3339 time or a duration", lockUntilArg)
+		}
+		if streamData {
+			fatal("-lockmode cannot be combined with -stream, uploads with retention need a Content-MD5")
+		}
+	}
+	if dateStyle != "amz" && dateStyle != "date" {
+		fatalf("Invalid -datestyle argument %q: expected amz or date", dateStyle)
+	}
+	if dialConcurrency < 0 {
+		fatalf("Invalid -dialconcurrency argument %d: must not be negative", dialConcurrency)
+	} else if dialConcurrency > 0 {
+		dialSlots = make(chan struct{}, dialConcurrency)
+	}
+	if readOnce && !sequentialRead {
+		fatal("-readonce needs -sequentialread")
+	}
+	if sequentialRead && (readAfterWrite || overwrite || singleKey != "") {
+		fatal("-sequentialread cannot be combined with -raw, -overwrite or -singlekey")
+	}
+	if verifyCount && (readAfterWrite || overwrite || workingSet > 0 || objectVersions > 1 || singleKey != "") {
+		fatal("-verifycount cannot be combined with -raw, -overwrite, -workingset, -versions or -singlekey")
+	}
+	if interleave && (readAfterWrite || overwrite || workingSet > 0 || bgDelete > 0 || resume || objectCount > 0 || opsArg != "") {
+		fatal("-interleave cannot be combined with -raw, -overwrite, -workingset, -bgdelete, -resume, -objectcount or -ops")
+	}
+	if opsArg != "" {
+		if err := parseMixedOps(opsArg); err != nil {
+			fatalf("Invalid -ops argument %q: %v", opsArg, err)
+		}
+		if readAfterWrite || overwrite || workingSet > 0 || bgDelete > 0 || objectVersions > 1 || singleKey != "" || objectCount > 0 {
+			fatal("-ops cannot be combined with -raw, -overwrite, -workingset, -bgdelete, -versions, -singlekey or -objectcount")
+		}
+	}
+	if mixSizesArg != "" {
+		if err := parseMixSizes(mixSizesArg); err != nil {
+			fatalf("Invalid -mixsizes argument %q: %v", mixSizesArg, err)
+		}
+		if threads < len(mixClasses) {
+			fatalf("-mixsizes needs at least one thread per size, %d threads for %d sizes", threads, len(mixClasses))
+		}
+		if sweepArg != "" || sizeJitter > 0 || readAfterWrite || overwrite || workingSet > 0 || bgDelete > 0 ||
+			calibrateSecs > 0 || fillToArg != "" || resume || singleKey != "" || objectCount > 0 {
+			fatal("-mixsizes cannot be combined with -zsweep, -sizejitter, -raw, -overwrite, -workingset, -bgdelete, -calibrate, -fillto, -resume, -singlekey or -objectcount")
+		}
+	}
+	if bgDelete < 0 || bgDelete >= 1 {
+		fatalf("Invalid -bgdelete argument %v: must be at least 0 and below 1", bgDelete)
+	}
+	if bgDelete > 0 && (threads < 2 || readAfterWrite || overwrite || workingSet > 0 || objectVersions > 1 || resume || fillToArg != "" || objectCount > 0) {
+		fatal("-bgdelete needs at least 2 threads and cannot be combined with -raw, -overwrite, -workingset, -versions, -resume, -fillto or -objectcount")
+	}
+	if workingSet < 0 {
+		fatalf("Invalid -workingset argument %d: must not be negative", workingSet)
+	}
+	if workingSet > 0 && (readAfterWrite || overwrite || objectVersions > 1 || resume || fillToArg != "") {
+		fatal("-workingset cannot be combined with -raw, -overwrite, -versions, -resume or -fillto")
+	}
+	if overwrite && (readAfterWrite || streamData || singleKey != "" || objectVersions > 1 || resume || fillToArg != "" || readSet > 0) {
+		fatal("-overwrite cannot be combined with -raw, -stream, -singlekey, -versions, -resume, -fillto or -readset")
+	}
+	if threadPool < 0 {
+		fatalf("Invalid -pool argument %d: must not be negative", threadPool)
+	}
+	if thinkArg != "" {
+		if err := parseThinkTime(thinkArg); err != nil {
+			fatalf("Invalid -thinktime argument %q: %v", thinkArg, err)
+		}
+	}
+	if connections < 0 {
+		fatalf("Invalid -connections argument %d: must not be negative", connections)
+	} else if connections > 0 {
+		connSlots = make(chan struct{}, connections)
+	}
+	if readSet < 0 {
+		fatalf("Invalid -readset argument %d: must not be negative", readSet)
+	}
+	if partNumber < 0 || partNumber > 10000 {
+		fatalf("Invalid -partnumber argument %d: must be between 1 and 10000, or 0 for whole objects", partNumber)
+	}
+	if objectVersions < 0 {
+		fatalf("Invalid -versions argument %d: must not be negative", objectVersions)
+	}
+	if trimPercent < 0 || trimPercent >= 100 {
+		fatalf("Invalid -trim argument %v: must be at least 0 and below 100", trimPercent)
+	}
+	if maxP99 < 0 {
+		fatalf("Invalid -p99-max argument %v: must not be negative", maxP99)
+	}
+	if calibrateSecs < 0 {
+		fatalf("Invalid -calibrate argument %d: must not be negative", calibrateSecs)
+	}
+	if cleanThreads < 1 {
+		fatalf("Invalid -cleanthreads argument %d: must be at least 1", cleanThreads)
+	}
+	if maxClean < 0 {
+		fatalf("Invalid -maxclean argument %d: must not be negative", maxClean)
+	}
+	if bucketWaitSecs < 0 {
+		fatalf("Invalid -bucketwait argument %d: must not be negative", bucketWaitSecs)
+	}
+	switch keyFormat {
+	case "seq":
+	case "random", "uuid":
+		if resume {
+			fatal("-resume needs -keyformat seq to find the last object uploaded")
+		}
+	default:
+		fatalf("Invalid -keyformat argument %q: expected seq, random or uuid", keyFormat)
+	}
+	if padWidth < 0 || padWidth > 19 {
+		fatalf("Invalid -padwidth argument %d: must be between 0 and 19", padWidth)
+	}
+	if padWidth > 0 && keyFormat != "seq" {
+		fatal("-padwidth cannot be combined with -keyformat random or uuid")
+	}
+	if keyDepth < 0 || keyDepth > 32 {
+		fatalf("Invalid -keydepth argument %d: must be between 0 and 32", keyDepth)
+	}
+	if !keepAlive {
+		HTTPTransport.(*http.Transport).DisableKeepAlives = true
+	}
+	// Each request in flight holds a connection, and those beyond the idle pool are closed once their request is
+	// done, so threads outnumbering it would keep dialing new connections instead of reusing them
+	inFlight := threads
+	for _, n := range []int{getThreads, deleteThreads} {
+		if n > inFlight {
+			inFlight = n
+		}
+	}
+	if threadPool > 0 && threadPool < inFlight {
+		inFlight = threadPool
+	}
+	if connections > 0 && connections < inFlight {
+		inFlight = connections
+	}
+	if transport := HTTPTransport.(*http.Transport); inFlight > transport.MaxIdleConnsPerHost {
+		transport.MaxIdleConnsPerHost = inFlight
+	}
+	if limit := openFileLimit(); limit > 0 && int64(inFlight) >= limit {
+		log.Printf("WARNING: Up to %d requests can be in flight, but the open file limit of %d caps the connections "+
+			"and the rest will fail with \"too many open files\"; raise it with ulimit -n, or lower -t, -pool or -connections",
+			inFlight, limit)
+	}
+	if sdkRetries < aws.UseServiceDefaultRetries {
+		fatalf("Invalid -sdkretries argument %d: must be -1 or more", sdkRetries)
+	}
+	urlHosts := strings.Split(urlArg, ",")
+	for i, host := range urlHosts {
+		if host == "" {
+			fatalf("Invalid -u argument %q: empty endpoint", urlArg)
+		} else if host == "mock" {
+			urlHosts[i] = startMockServer()
+		}
+	}
+	if signingService == "" || strings.ContainsAny(signingService, "/ ") {
+		fatalf("Invalid -service argument %q: expected a service name such as s3", signingService)
+	}
+	regions := strings.Split(regionArg, ",")
+	if len(regions) == 1 {
+		for len(regions) < len(urlHosts) {
+			regions = append(regions, regionArg)
+		}
+	} else if len(regions) != len(urlHosts) {
+		fatalf("Invalid -region argument %q: expected one region or one per -u endpoint (%d)", regionArg, len(urlHosts))
+	}
+	for _, r := range regions {
+		if r == "" {
+			fatalf("Invalid -region argument %q: empty region", regionArg)
+		}
+	}
+	if accelerate {
+		if len(urlHosts) > 1 {
+			fatal("-accelerate cannot be combined with more than one -u endpoint")
+		}
+		if !accelerateBucketName(bucket) {
+			fatalf("Invalid -b argument %q for -accelerate: the bucket name must be DNS compatible and contain no dots", bucket)
+		}
+		urlHosts[0] = accelerateEndpoint(urlHosts[0])
+	}
+	urlHost, region = urlHosts[0], regions[0]
+	var err error
+	if objectSize, err = parseSize(sizeArg); err != nil {
+		fatalf("Invalid -z argument for object size %q: %v", sizeArg, err)
+	}
+	if sizeJitter < 0 || sizeJitter >= 100 {
+		fatalf("Invalid -sizejitter argument %v: must be at least 0 and below 100", sizeJitter)
+	}
+	sizes := []string{sizeArg}
+	zeroSize := objectSize == 0
+	if sweepArg != "" {
+		sizes = strings.Split(sweepArg, ",")
+		zeroSize = false
+		for _, size := range sizes {
+			if n, err := parseSize(size); err != nil {
+				fatalf("Invalid -zsweep argument for object size %q: %v", size, err)
+			} else if n == 0 {
+				zeroSize = true
+			}
+		}
+	}
+	if zeroSize && (overwrite || fillToArg != "" || minThroughputArg != "") {
+		// Zero-byte objects carry no generation to read back, fill nothing and move no bytes
+		fatal("-overwrite, -fillto and -minthroughput need objects larger than zero bytes")
+	}
+	if fillToArg != "" {
+		if fillTo, err = parseSize(fillToArg); err != nil || fillTo == 0 {
+			fatalf("Invalid -fillto argument %q: must be a size greater than zero", fillToArg)
+		}
+		if maxObjects > 0 || readAfterWrite || calibrateSecs > 0 {
+			fatal("-fillto cannot be combined with -maxobjects, -raw or -calibrate")
+		}
+	}
+	if minThroughputArg != "" {
+		if minThroughput, err = parseSize(minThroughputArg); err != nil {
+			fatalf("Invalid -minthroughput argument %q: %v", minThroughputArg, err)
+		}
+	}
+
+	for _, buf := range []struct {
+		name, arg string
+		size      *int
+	}{{"sndbuf", sndBufArg, &sndBuf}, {"rcvbuf", rcvBufArg, &rcvBuf}} {
+		if buf.arg == "" {
+			continue
+		}
+		n, err := parseSize(buf.arg)
+		if err != nil || n == 0 || n > math.MaxInt32 {
+			fatalf("Invalid -%s argument %q: must be a size between 1 and 2G", buf.name, buf.arg)
+		}
+		if !socketBuffersSupported {
+			fatalf("-%s is not supported on this platform", buf.name)
+		}
+		*buf.size = int(n)
+	}
+
+	type parameters struct {
+		Label     string  `json:"label,omitempty"`
+		Client    string  `json:"client,omitempty"`
+		URLHost   string  `json:"urlHost"`
+		Region    string  `json:"region,omitempty"`
+		Service   string  `json:"service,omitempty"`
+		Bucket    string  `json:"bucket"`
+		Duration  int     `json:"duration"`
+		Threads   int     `json:"threads"`
+		Gets      int     `json:"getThreads"`
+		Deletes   int     `json:"deleteThreads"`
+		Pool      int     `json:"pool,omitempty"`
+		Conns     int     `json:"connections,omitempty"`
+		ThinkTime string  `json:"thinkTime,omitempty"`
+		Loops     int     `json:"loops"`
+		Size      string  `json:"sizeArg"`
+		ACL       string  `json:"acl,omitempty"`
+		CacheCtl  string  `json:"cacheControl,omitempty"`
+		Disposn   string  `json:"disposition,omitempty"`
+		LockMode  string  `json:"lockMode,omitempty"`
+		LockUntil string  `json:"lockUntil,omitempty"`
+		MaxObjs   int64   `json:"maxObjects,omitempty"`
+		WorkSet   int64   `json:"workingSet,omitempty"`
+		BgDelete  float64 `json:"bgDelete,omitempty"`
+		DialConc  int     `json:"dialConcurrency,omitempty"`
+		FillTo    string  `json:"fillTo,omitempty"`
+		ObjCount  int64   `json:"objectCount,omitempty"`
+		ReadSet   int64   `json:"readSet,omitempty"`
+		SingleKey string  `json:"singleKey,omitempty"`
+		PartNum   int     `json:"partNumber,omitempty"`
+		Suffix    string  `json:"suffix,omitempty"`
+		KeyDepth  int     `json:"keyDepth,omitempty"`
+		PadWidth  int     `json:"padWidth,omitempty"`
+		KeyFormat string  `json:"keyFormat,omitempty"`
+		Seed      int64   `json:"seed,omitempty"`
+		Versions  int     `json:"versions,omitempty"`
+		Stream    bool    `json:"stream,omitempty"`
+		Expect    bool    `json:"expect100,omitempty"`
+		Chunked   bool    `json:"chunked,omitempty"`
+		NoDrain   bool    `json:"nodrain,omitempty"`
+		NoDelay   *bool   `json:"nodelay,omitempty"`
+		KeepAlive *bool   `json:"keepalive,omitempty"`
+		Rampup    int     `json:"rampup,omitempty"`
+		MaxP99    float64 `json:"p99Max,omitempty"`
+		Calib     int     `json:"calibrate,omitempty"`
+		Sweep     string  `json:"zsweep,omitempty"`
+		Jitter    float64 `json:"sizeJitter,omitempty"`
+		MixSizes  string  `json:"mixSizes,omitempty"`
+		HostHdr   string  `json:"hostHeader,omitempty"`
+		Query     string  `json:"query,omitempty"`
+		Anon      string  `json:"anonymous,omitempty"`
+		DateStyle string  `json:"dateStyle,omitempty"`
+		Accel     bool    `json:"accelerate,omitempty"`
+		RAW       bool    `json:"raw,omitempty"`
+		Overwrite bool    `json:"overwrite,omitempty"`
+		Attrs     bool    `json:"attributes,omitempty"`
+		Delimiter string  `json:"delimiter,omitempty"`
+		Ops       string  `json:"ops,omitempty"`
+		Interlv   bool    `json:"interleave,omitempty"`
+		VerifyCnt bool    `json:"verifyCount,omitempty"`
+		SeqRead   bool    `json:"sequentialRead,omitempty"`
+		ReadOnce  bool    `json:"readOnce,omitempty"`
+		Verify    bool    `json:"verify,omitempty"`
+		VerifyDel bool    `json:"verifyDelete,omitempty"`
+		Resume    bool    `json:"resume,omitempty"`
+		Retries   *int    `json:"sdkRetries,omitempty"`
+	}
+
+	// Echo the parameters
+	if !jsonPrint {
+		threadCounts := strconv.Itoa(threads)
+		if getThreads != threads || deleteThreads != threads {
+			threadCounts = fmt.Sprintf("%d,%d,%d", threads, getThreads, deleteThreads)
+		}
+		params := fmt.Sprintf("Parameters: url=%s, bucket=%s, duration=%d, threads=%s, loops=%d, size=%s",
+			strings.Join(urlHosts, ","), bucket, durationSecs, threadCounts, loops, sizeArg)
+		if regionArg != "us-east-1" {
+			params += ", region=" + regionArg
+		}
+		if signingService != "s3" {
+			params += ", service=" + signingService
+		}
+		if threadPool > 0 {
+			params += fmt.Sprintf(", pool=%d", threadPool)
+		}
+		if connections > 0 {
+			params += fmt.Sprintf(", connections=%d", connections)
+		}
+		if thinkMax > 0 {
+			params += ", thinktime=" + thinkArg
+		}
+		if runLabel != "" {
+			params += ", label=" + runLabel
+		}
+		if clientArg != "raw" {
+			params += ", client=" + clientArg
+		}
+		if objectACL != "" {
+			params += ", acl=" + objectACL
+		}
+		if cacheControl != "" {
+			params += ", cachecontrol=" + cacheControl
+		}
+		if contentDisposition != "" {
+			params += ", disposition=" + contentDisposition
+		}
+		if lockMode != "" {
+			params += ", lockmode=" + lockMode + ", lockuntil=" + lockUntil.Format(time.RFC3339)
+		}
+		if maxObjects > 0 {
+			params += fmt.Sprintf(", maxobjects=%d", maxObjects)
+		}
+		if fillToArg != "" {
+			params += ", fillto=" + fillToArg
+		}
+		if workingSet > 0 {
+			params += fmt.Sprintf(", workingset=%d", workingSet)
+		}
+		if bgDelete > 0 {
+			params += fmt.Sprintf(", bgdelete=%v", bgDelete)
+		}
+		if dialConcurrency > 0 {
+			params += fmt.Sprintf(", dialconcurrency=%d", dialConcurrency)
+		}
+		if objectCount > 0 {
+			params += fmt.Sprintf(", objectcount=%d", objectCount)
+		}
+		if readSet > 0 {
+			params += fmt.Sprintf(", readset=%d", readSet)
+		}
+		if singleKey != "" {
+			params += ", singlekey=" + singleKey
+		}
+		if resume {
+			params += ", resume=true"
+		}
+		if partNumber > 0 {
+			params += fmt.Sprintf(", partnumber=%d", partNumber)
+		}
+		if objectSuffix != "" {
+			params += ", suffix=" + objectSuffix
+		}
+		if keyDepth > 0 {
+			params += fmt.Sprintf(", keydepth=%d", keyDepth)
+		}
+		if padWidth > 0 {
+			params += fmt.Sprintf(", padwidth=%d", padWidth)
+		}
+		if keyFormat != "seq" {
+			params += fmt.Sprintf(", keyformat=%s, seed=%d", keyFormat, keySeed)
+		}
+		if objectVersions > 1 {
+			params += fmt.Sprintf(", versions=%d", objectVersions)
+		}
+		if streamData {
+			params += ", stream=true"
+		}
+		if expect100 {
+			params += ", expect100=true"
+		}
+		if chunkedUpload {
+			params += ", chunked=true"
+		}
+		if !noDelay {
+			params += ", nodelay=false"
+		}
+		if !keepAlive {
+			params += ", keepalive=false"
+		}
+		if noDrain {
+			params += ", nodrain=true"
+		}
+		if rampupSecs > 0 {
+			params += fmt.Sprintf(", rampup=%d", rampupSecs)
+		}
+		if maxP99 > 0 {
+			params += fmt.Sprintf(", p99-max=%vms", maxP99)
+		}
+		if calibrateSecs > 0 {
+			params += fmt.Sprintf(", calibrate=%d", calibrateSecs)
+		}
+		if sweepArg != "" {
+			params += ", zsweep=" + sweepArg
+		}
+		if sizeJitter > 0 {
+			params += fmt.Sprintf(", sizejitter=%v%%", sizeJitter)
+		}
+		if mixSizesArg != "" {
+			params += ", mixsizes=" + mixSizesArg
+		}
+		if hostHeader != "" {
+			params += ", hosthdr=" + hostHeader
+		}
+		if len(extraQuery) > 0 {
+			params += ", query=" + extraQuery.String()
+		}
+		if anonymousArg != "" {
+			params += ", anonymous=" + anonymousArg
+		}
+		if dateStyle != "amz" {
+			params += ", datestyle=" + dateStyle
+		}
+		if accelerate {
+			params += ", accelerate=true"
+		}
+		if readAfterWrite {
+			params += fmt.Sprintf(", raw=true, verify=%t", verifyData)
+		}
+		if overwrite {
+			params += ", overwrite=true"
+		}
+		if objectAttributes {
+			params += ", attributes=true"
+		}
+		if listDelimiter != "" {
+			params += fmt.Sprintf(", delimiter=%q", listDelimiter)
+		}
+		if opsArg != "" {
+			params += ", ops=" + opsArg
+		}
+		if interleave {
+			params += ", interleave=true"
+		}
+		if verifyCount {
+			params += ", verifycount=true"
+		}
+		if sequentialRead {
+			params += fmt.Sprintf(", sequentialread=true, readonce=%t", readOnce)
+		}
+		if verifyDelete {
+			params += ", verifydelete=true"
+		}
+		if sdkRetries != aws.UseServiceDefaultRetries {
+			params += fmt.Sprintf(", sdkretries=%d", sdkRetries)
+		}
+		fmt.Println(params)
+	} else {
+		echo := parameters{
+			Label:     runLabel,
+			Client:    clientArg,
+			URLHost:   strings.Join(urlHosts, ","),
+			Region:    regionArg,
+			Bucket:    bucket,
+			Duration:  durationSecs,
+			Threads:   threads,
+			Gets:      getThreads,
+			Deletes:   deleteThreads,
+			Pool:      threadPool,
+			Conns:     connections,
+			ThinkTime: thinkArg,
+			Loops:     loops,
+			Size:      sizeArg,
+			ACL:       objectACL,
+			CacheCtl:  cacheControl,
+			Disposn:   contentDisposition,
+			LockMode:  lockMode,
+			MaxObjs:   maxObjects,
+			WorkSet:   workingSet,
+			BgDelete:  bgDelete,
+			DialConc:  dialConcurrency,
+			FillTo:    fillToArg,
+			ObjCount:  objectCount,
+			ReadSet:   readSet,
+			SingleKey: singleKey,
+			PartNum:   partNumber,
+			Suffix:    objectSuffix,
+			KeyDepth:  keyDepth,
+			PadWidth:  padWidth,
+			Seed:      keySeed,
+			Versions:  objectVersions,
+			Stream:    streamData,
+			Expect:    expect100,
+			Chunked:   chunkedUpload,
+			NoDrain:   noDrain,
+			Rampup:    rampupSecs,
+			MaxP99:    maxP99,
+			Calib:     calibrateSecs,
+			Sweep:     sweepArg,
+			Jitter:    sizeJitter,
+			MixSizes:  mixSizesArg,
+			HostHdr:   hostHeader,
+			Query:     extraQuery.String(),
+			Anon:      anonymousArg,
+			RAW:       readAfterWrite,
+			Overwrite: overwrite,
+			Attrs:     objectAttributes,
+			Delimiter: listDelimiter,
+			Ops:       opsArg,
+			Interlv:   interleave,
+			VerifyCnt: verifyCount,
+			SeqRead:   sequentialRead,
+			ReadOnce:  readOnce,
+			Verify:    verifyData,
+			VerifyDel: verifyDelete,
+			Resume:    resume,
+		}
+		if sdkRetries != aws.UseServiceDefaultRetries {
+			echo.Retries = &sdkRetries
+		}
+		if !noDelay {
+			echo.NoDelay = &noDelay
+		}
+		if !keepAlive {
+			echo.KeepAlive = &keepAlive
+		}
+		if keyFormat != "seq" {
+			echo.KeyFormat = keyFormat
+		}
+		if signingService != "s3" {
+			echo.Service = signingService
+		}
+		if dateStyle != "amz" {
+			echo.DateStyle = dateStyle
+		}
+		if accelerate {
+			echo.Accel = true
+		}
+		if lockMode != "" {
+			echo.LockUntil = lockUntil.Format(time.RFC3339)
+		}
+		data, err := json.Marshal(echo)
+		if err != nil {
+			fatal(err)
+		}
+		if ndjson {
+			fmt.Println(tagJSON(string(data), "type", "parameters"))
+		} else {
+			fmt.Println(string(data))
+		}
+	}
+
+	// Stop cleanly on Ctrl-C
+	handleInterrupts()
+
+	// Open the results log, it is fine to run without one
+	logfile, _ = openResultFile("benchmark.log", os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	var header headerMessage
+	if writeHeader {
+		header = newHeaderMessage(myflag)
+		logit(header)
+	}
+
+	if metricsAddr != "" {
+		serveMetrics()
+	}
+
+	// Open the per-request trace, which every request then goes through
+	if tracePath != "" {
+		if err := openTrace(); err != nil {
+			fatalf("Unable to create trace file %s: %v", tracePath, err)
+		}
+		httpClient.Transport = traceTransport{HTTPTransport}
+	}
+
+	// Open the time-series output
+	if timeseriesPath != "" {
+		if timeseriesFile, err = openResultFile(timeseriesPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC); err != nil {
+			fatalf("Unable to create time-series file %s: %v", timeseriesPath, err)
+		}
+		if writeHeader {
+			// Comment lines, which most CSV readers can be told to skip
+			timeseriesFile.Write([]byte(header.String() + "\n"))
+		}
+		timeseries = csv.NewWriter(timeseriesFile)
+		header := []string{"loop", "method", "elapsed", "ops_per_sec", "bytes_per_sec"}
+		if runLabel != "" {
+			header = append(header, "label")
+		}
+		timeseries.Write(header)
+		timeseries.Flush()
+	}
+
+	// Loop running the tests, once per endpoint
+	var total summaryMessage
+	var endpoints []endpointMessage
+	var headline sweepMessage
+	runStart := time.Now()
+	for i := range urlHosts {
+		urlHost, region = urlHosts[i], regions[i]
+		if len(urlHosts) > 1 {
+			endpointLabel = urlHost
+			if !jsonPrint {
+				fmt.Printf("Endpoint %d of %d: %s, region %s\n", i+1, len(urlHosts), urlHost, region)
+			}
+		}
+		// Create the bucket, check requests are accepted and delete all the objects unless resuming
+		createBucket()
+		if clientArg == "sdk" {
+			sdkClient = getS3Client()
+		} else {
+			preflight()
+		}
+		if sndBuf > 0 || rcvBuf > 0 {
+			logit(socketMessage{SndBuf: sndBuf, SndBufActual: actualSndBuf, RcvBuf: rcvBuf, RcvBufActual: actualRcvBuf})
+		}
+		if resume {
+			resumeCount = findResumePoint()
+		} else if singleKey == "" {
+			deleteAllObjects()
+		}
+		if singleKey != "" {
+			checkSingleKey()
+		}
+
+		// Loop running the tests, once per object size
+		var sweep, coldSteady []sweepMessage
+		cmp := endpointMessage{Endpoint: urlHost, Region: region, sweepMessage: sweepMessage{Size: strings.Join(sizes, ",")}}
+		for _, size := range sizes {
+			objectSize, _ = parseSize(size)
+			if mixClasses != nil {
+				// The upload data must hold the largest size
+				objectSize = mixMaxSize()
+			}
+			if fillTo > 0 {
+				// The object count that reaches the fill size depends on the object size
+				maxObjects = fillObjects()
+			}
+			if len(sizes) > 1 {
+				sizeLabel = size
+			}
+			// Initialize data for the bucket
+			if !streamData {
+				// Large enough for the biggest jittered object
+				objectData = make([]byte, objectSize+uint64(math.Ceil(float64(objectSize)*sizeJitter/100)))
+				rand.Read(objectData)
+			}
+			if showCompression && objectSize > 0 {
+				logit(compressionRatio())
+			}
+			row := sweepMessage{Size: size}
+			cold := sweepMessage{Size: size, Period: "cold"}
+			steady := sweepMessage{Size: size, Period: "steady"}
+			var basePut, baseGet, baseDel, lastPut, lastGet, lastDel logMessage
+			if calibrateSecs > 0 {
+				basePut, baseGet, baseDel = calibrate()
+			}
+			for loop := 1; loop <= loops; loop++ {
+				put, get, del := runLoop(loop, &total)
+				lastPut, lastGet, lastDel = put, get, del
+				row.add(put, get, del, loops)
+				cmp.add(put, get, del, loops*len(sizes))
+				headline.add(put, get, del, loops*len(sizes)*len(urlHosts))
+				if loop == 1 {
+					cold.add(put, get, del, 1)
+				} else {
+					steady.add(put, get, del, loops-1)
+				}
+			}
+			sweep = append(sweep, row)
+			coldSteady = append(coldSteady, cold, steady)
+			if calibrateSecs > 0 {
+				logit(newScalingMessage(basePut, lastPut, row.PutOps))
+				logit(newScalingMessage(baseGet, lastGet, row.GetOps))
+				logit(newScalingMessage(baseDel, lastDel, row.DeleteOps))
+			}
+		}
+
+		// Size sweep table
+		if len(sizes) > 1 {
+			if !jsonPrint {
+				fmt.Println(sweepHeader)
+			}
+			for _, row := range sweep {
+				logit(row)
+			}
+		}
+
+		// First loop against the mean of the rest
+		if loops > 1 {
+			if !jsonPrint {
+				fmt.Println("Cold (first loop) and steady (mean of later loops) results:")
+				fmt.Println(sweepHeader)
+			}
+			for _, row := range coldSteady {
+				logit(row)
+			}
+		}
+		endpoints = append(endpoints, cmp)
+	}
+
+	// Endpoint comparison table
+	if len(endpoints) > 1 {
+		if !jsonPrint {
+			fmt.Println(endpointHeader)
+		}
+		for _, row := range endpoints {
+			logit(row)
+		}
+	}
+
+	// Grand totals
+	total.LogTime = time.Now()
+	total.Time = total.LogTime.Sub(runStart).Seconds()
+	logit(total)
+	if metricsFile != "" {
+		if err := writeMetrics(total); err != nil {
+			fatalf("Unable to write metrics file %s: %v", metricsFile, err)
+		}
+	}
+
+	// All done
+	if !jsonPrint {
+		fmt.Println("Benchmark completed.")
+	}
+	closeResults()
+	closeTrace()
+	if showBanner {
+		printBanner(total, headline)
+		if len(thresholdFailures) > 0 {
+			os.Exit(1)
+		}
 	}
-	logfile.Close()
 }
@@ -0,0 +1,181 @@
+// s3-benchmark_test.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCannedACLPut -- a PUT with x-amz-acl signs the header and is accepted by the mock endpoint
+func TestCannedACLPut(t *testing.T) {
+	accessKey, secretKey = "AKIAEXAMPLE", "secret"
+	endpoint := startMockServer()
+	req, _ := http.NewRequest(http.MethodPut, endpoint+"/acl-test", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("creating bucket: %v", err)
+	}
+	resp.Body.Close()
+
+	req, _ = http.NewRequest(http.MethodPut, endpoint+"/acl-test/Object-1", bytes.NewReader([]byte("data")))
+	req.Header.Set("X-Amz-Acl", "public-read")
+	setSignature(req)
+
+	if headers := canonicalAmzHeaders(req); !strings.Contains(headers, "x-amz-acl:public-read\n") {
+		t.Errorf("canonical headers %q do not include the ACL", headers)
+	}
+	// Recompute the signature by hand over the string to sign with and without the ACL line
+	date := req.Header.Get("X-Amz-Date")
+	sign := func(amzHeaders string) string {
+		mac := hmac.New(sha1.New, []byte(secretKey))
+		mac.Write([]byte("PUT\n\n\n\n" + amzHeaders + "/acl-test/Object-1"))
+		return "AWS " + accessKey + ":" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	auth := req.Header.Get("Authorization")
+	if want := sign("x-amz-acl:public-read\nx-amz-date:" + date + "\n"); auth != want {
+		t.Errorf("Authorization = %q, want %q", auth, want)
+	}
+	if unsigned := sign("x-amz-date:" + date + "\n"); auth == unsigned {
+		t.Error("signature does not cover x-amz-acl")
+	}
+
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("PUT with x-amz-acl: status %d, want 200", resp.StatusCode)
+	}
+}
+
+// TestRunLoop -- a loop of PUT, GET and DELETE phases against the mock endpoint accounts for every object
+func TestRunLoop(t *testing.T) {
+	accessKey, secretKey = "AKIAEXAMPLE", "secret"
+	urlHost, bucket = startMockServer(), "loop-test"
+	durationSecs, threads, getThreads, deleteThreads = 1, 2, 2, 2
+	objectSize = 1024
+	objectData = make([]byte, objectSize)
+	req, _ := http.NewRequest(http.MethodPut, urlHost+"/"+bucket, nil)
+	setSignature(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("creating bucket: %v", err)
+	}
+	resp.Body.Close()
+
+	var total summaryMessage
+	put, get, del := runLoop(1, &total)
+	if put.Objects == 0 || put.Errors != 0 {
+		t.Errorf("PUT phase: %d objects, %d errors, want some objects and no errors", put.Objects, put.Errors)
+	}
+	if want := uint64(put.Objects) * objectSize; total.BytesUploaded != want {
+		t.Errorf("uploaded %d bytes, want %d for %d objects", total.BytesUploaded, want, put.Objects)
+	}
+	if get.Objects == 0 || get.Errors != 0 {
+		t.Errorf("GET phase: %d objects, %d errors, want some objects and no errors", get.Objects, get.Errors)
+	}
+	if want := uint64(get.Objects) * objectSize; total.BytesDownloaded != want {
+		t.Errorf("downloaded %d bytes, want %d for %d objects", total.BytesDownloaded, want, get.Objects)
+	}
+	if deleteCount < uploadCount || del.Errors != 0 {
+		t.Errorf("DELETE phase: %d of %d objects deleted, %d errors", deleteCount, uploadCount, del.Errors)
+	}
+	if total.Objects != put.Objects || total.Errors != 0 {
+		t.Errorf("totals: %d objects, %d errors, want %d objects and no errors", total.Objects, total.Errors, put.Objects)
+	}
+	resp, err = http.Get(urlHost + "/" + bucket)
+	if err != nil {
+		t.Fatalf("listing bucket: %v", err)
+	}
+	listing, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if bytes.Contains(listing, []byte("<Contents>")) {
+		t.Errorf("objects left in the bucket after the DELETE phase: %s", listing)
+	}
+}
+
+// TestRedirectKeepsRequest -- a redirect hop keeps the original request's context and -hosthdr Host
+func TestRedirectKeepsRequest(t *testing.T) {
+	accessKey, secretKey = "AKIAEXAMPLE", "secret"
+	var gotHost string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost = r.Host
+	}))
+	defer target.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer redirect.Close()
+
+	var conns int64
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { atomic.AddInt64(&conns, 1) },
+	})
+	req, _ := http.NewRequest(http.MethodGet, redirect.URL+"/redirect-test/Object-1", nil)
+	req = req.WithContext(ctx)
+	req.Host = "virtual.example"
+	signRequest(req)
+	resp, err := doRequest(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status %d after the redirect, want 200", resp.StatusCode)
+	}
+	if gotHost != "virtual.example" {
+		t.Errorf("redirected request sent Host %q, want virtual.example", gotHost)
+	}
+	if conns != 2 {
+		t.Errorf("the request's trace saw %d connections, want 2, one per hop", conns)
+	}
+}
+
+// TestMaxCleanRefuses -- a cleanup over -maxclean deletes nothing, even past the first page of 1000 objects, unless
+// -forceclean is set
+func TestMaxCleanRefuses(t *testing.T) {
+	accessKey, secretKey = "AKIAEXAMPLE", "secret"
+	m := &mockServer{buckets: make(map[string]map[string][]byte)}
+	server := httptest.NewServer(m)
+	defer server.Close()
+	urlHost, bucket, cleanPrefix, cleanThreads = server.URL, "clean-test", "", 2
+	defer func() { maxClean, forceClean = 0, false }()
+	m.buckets[bucket] = make(map[string][]byte)
+	for i := 1; i <= 1500; i++ {
+		m.buckets[bucket][fmt.Sprintf("Object-%d", i)] = []byte("data")
+	}
+	count := func() int {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return len(m.buckets[bucket])
+	}
+
+	maxClean = 1200
+	if err := cleanBucket(); err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Errorf("cleanBucket with 1500 objects and -maxclean 1200 = %v, want a refusal", err)
+	}
+	if n := count(); n != 1500 {
+		t.Errorf("%d objects left after a refused cleanup, want all 1500", n)
+	}
+	forceClean = true
+	if err := cleanBucket(); err != nil {
+		t.Errorf("cleanBucket with -forceclean: %v", err)
+	}
+	if n := count(); n != 0 {
+		t.Errorf("%d objects left after a forced cleanup, want 0", n)
+	}
+}
@@ -0,0 +1,157 @@
+// sdkclient.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// sdkClient -- set by -client sdk to send PUTs, GETs and DELETEs, and the MIXED phase's HEADs and copies, through the
+// SDK instead of the signed HTTP path
+var sdkClient *s3.S3
+
+// sdkRequestError -- print a failed SDK request and return its HTTP status, exiting if there was no response
+func sdkRequestError(op, key string, err error) int {
+	failure, ok := err.(awserr.RequestFailure)
+	if !ok {
+		fatalf("FATAL: Error in %s of object %s: %v", op, key, err)
+	}
+	out := os.Stdout
+	if ndjson {
+		// Keep stdout to result records only
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "%s %s failed with status %d (x-amz-request-id: %s): %v\n", op, key, failure.StatusCode(),
+		failure.RequestID(), err)
+	return failure.StatusCode()
+}
+
+// sdkUploadObject -- uploadObject through the SDK client
+func sdkUploadObject(objnum int64) (time.Duration, bool) {
+	size := objectSizeFor(objnum)
+	key := objectKey(objnum)
+	in := &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		Body:          bytes.NewReader(objectBytes(objnum, size)),
+		ContentLength: aws.Int64(int64(size)),
+	}
+	if objectACL != "" {
+		in.ACL = aws.String(objectACL)
+	}
+	if cacheControl != "" {
+		in.CacheControl = aws.String(cacheControl)
+	}
+	if contentDisposition != "" {
+		in.ContentDisposition = aws.String(contentDisposition)
+	}
+	if lockMode != "" {
+		in.ObjectLockMode = aws.String(lockMode)
+		in.ObjectLockRetainUntilDate = aws.Time(lockUntil)
+		sum := md5.Sum(objectBytes(objnum, size))
+		in.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	start := time.Now()
+	_, err := sdkClient.PutObjectWithContext(phaseCtx, in)
+	elapsed := time.Since(start)
+	if err != nil && phaseCtx.Err() != nil {
+		return elapsed, false
+	} else if err != nil {
+		atomic.AddInt64(&errorCount, 1)
+		sdkRequestError("Upload", key, err)
+		return elapsed, false
+	}
+	atomic.AddInt64(&bytesDone, int64(size))
+	return elapsed, true
+}
+
+// sdkDownloadObject -- downloadObject through the SDK client
+func sdkDownloadObject(objnum int64, verify bool) (time.Duration, int, int64, bool) {
+	key := objectKey(objnum)
+	if singleKey != "" {
+		key = singleKey
+	}
+	start := time.Now()
+	out, err := sdkClient.GetObjectWithContext(phaseCtx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil && phaseCtx.Err() != nil {
+		return time.Since(start), 0, 0, false
+	} else if err != nil {
+		elapsed := time.Since(start)
+		return elapsed, sdkRequestError("Download", key, err), 0, false
+	}
+	body := &countingReader{r: out.Body}
+	matched := verify && matchesObject(body, objnum)
+	if !noDrain {
+		io.Copy(ioutil.Discard, body)
+	}
+	out.Body.Close()
+	return time.Since(start), http.StatusOK, body.n, matched
+}
+
+// sdkObjectExists -- the SDK version of objectExists
+func sdkObjectExists(objnum int64) bool {
+	_, err := sdkClient.HeadObjectWithContext(phaseCtx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(objectKey(objnum))})
+	if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == http.StatusNotFound {
+		return false
+	}
+	return true
+}
+
+// sdkHeadObject -- headObject through the SDK client
+func sdkHeadObject(objnum int64) time.Duration {
+	key := objectKey(objnum)
+	start := time.Now()
+	_, err := sdkClient.HeadObjectWithContext(phaseCtx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	elapsed := time.Since(start)
+	if err != nil && phaseCtx.Err() == nil {
+		atomic.AddInt64(&errorCount, 1)
+		sdkRequestError("Head", key, err)
+	}
+	return elapsed
+}
+
+// sdkCopyObject -- copyObject through the SDK client
+func sdkCopyObject(src, dst int64) (time.Duration, bool) {
+	key := objectKey(dst)
+	in := &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(bucket + "/" + (&url.URL{Path: objectKey(src)}).EscapedPath()),
+	}
+	start := time.Now()
+	_, err := sdkClient.CopyObjectWithContext(phaseCtx, in)
+	elapsed := time.Since(start)
+	if err != nil && phaseCtx.Err() == nil {
+		atomic.AddInt64(&errorCount, 1)
+		sdkRequestError("Copy", key, err)
+	}
+	return elapsed, err == nil
+}
+
+// sdkDeleteObject -- deleteObject through the SDK client
+func sdkDeleteObject(objnum int64) time.Duration {
+	key := objectKey(objnum)
+	start := time.Now()
+	_, err := sdkClient.DeleteObjectWithContext(phaseCtx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	elapsed := time.Since(start)
+	if err != nil && phaseCtx.Err() == nil {
+		atomic.AddInt64(&errorCount, 1)
+		sdkRequestError("Delete", key, err)
+	}
+	return elapsed
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+// sockbuf_other.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import "errors"
+
+const socketBuffersSupported = false
+
+func setSocketBuffers(fd uintptr) error {
+	return errors.New("socket buffer sizes are not supported on this platform")
+}
@@ -0,0 +1,34 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+// sockbuf_unix.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const socketBuffersSupported = true
+
+// setSocketBuffers -- apply -sndbuf and -rcvbuf to a socket before it connects
+func setSocketBuffers(fd uintptr) error {
+	if sndBuf > 0 {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF, sndBuf); err != nil {
+			return fmt.Errorf("setting SO_SNDBUF: %v", err)
+		}
+	}
+	if rcvBuf > 0 {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF, rcvBuf); err != nil {
+			return fmt.Errorf("setting SO_RCVBUF: %v", err)
+		}
+	}
+	// The kernel may round or cap the request, so record what it actually gave us
+	socketBuffersOnce.Do(func() {
+		actualSndBuf, _ = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+		actualRcvBuf, _ = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+	})
+	return nil
+}
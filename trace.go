@@ -0,0 +1,199 @@
+// trace.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/bytefmt"
+)
+
+// traceBreakdown -- set by -breakdown to time the stages of each request with httptrace
+var traceBreakdown bool
+
+// Nanoseconds spent in each request stage during a phase, and the connections opened
+var dnsNanos, connectNanos, tlsNanos, firstByteNanos, newConns int64
+
+// The connections a phase's requests went out on, to tell how many requests each one served
+var connsUsed = map[net.Conn]bool{}
+var connsMu sync.Mutex
+
+// breakdown -- the average time a phase's requests spent in each stage, in milliseconds
+type breakdown struct {
+	DNS         float64 `json:"dns"`
+	Connect     float64 `json:"connect"`
+	TLS         float64 `json:"tls"`
+	FirstByte   float64 `json:"firstByte"`
+	Connections int64   `json:"connections"`
+	Used        int     `json:"connectionsUsed"`
+	PerConn     float64 `json:"requestsPerConnection"`
+}
+
+func (b breakdown) String() string {
+	return fmt.Sprintf(", dns/connect/tls/first byte = %.2f/%.2f/%.2f/%.2f ms, %d connections (%d new), %.1f requests per connection",
+		b.DNS, b.Connect, b.TLS, b.FirstByte, b.Used, b.Connections, b.PerConn)
+}
+
+// resetBreakdown -- clear the stage timings at the start of a phase
+func resetBreakdown() {
+	dnsNanos, connectNanos, tlsNanos, firstByteNanos, newConns = 0, 0, 0, 0, 0
+	connsMu.Lock()
+	connsUsed = map[net.Conn]bool{}
+	connsMu.Unlock()
+}
+
+// setBreakdown -- average the phase's stage timings over its requests
+// Reused connections skip DNS, connect and TLS, so those averages show how much connection setup costs overall
+func setBreakdown(l *logMessage) {
+	if !traceBreakdown || opsDone == 0 {
+		return
+	}
+	ms := func(total int64) float64 { return float64(total) / float64(opsDone) / float64(time.Millisecond) }
+	connsMu.Lock()
+	used := len(connsUsed)
+	connsMu.Unlock()
+	var perConn float64
+	if used > 0 {
+		perConn = float64(opsDone) / float64(used)
+	}
+	l.Breakdown = &breakdown{
+		DNS:         ms(dnsNanos),
+		Connect:     ms(connectNanos),
+		TLS:         ms(tlsNanos),
+		FirstByte:   ms(firstByteNanos),
+		Connections: newConns,
+		Used:        used,
+		PerConn:     perConn,
+	}
+}
+
+// requestTrace -- the stage start times of one request; dials can run on other goroutines
+type requestTrace struct {
+	mu                               sync.Mutex
+	dnsStart, connectStart, tlsStart time.Time
+	wroteRequest                     time.Time
+}
+
+func (t *requestTrace) start(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *requestTrace) done(at *time.Time, total *int64) {
+	t.mu.Lock()
+	atomic.AddInt64(total, int64(time.Since(*at)))
+	t.mu.Unlock()
+}
+
+// traceRequest -- attach the -breakdown stage timers to a request
+func traceRequest(req *http.Request) *http.Request {
+	t := &requestTrace{}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.start(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.done(&t.dnsStart, &dnsNanos) },
+		ConnectStart: func(network, addr string) {
+			t.start(&t.connectStart)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.done(&t.connectStart, &connectNanos)
+			if err == nil {
+				atomic.AddInt64(&newConns, 1)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			connsMu.Lock()
+			connsUsed[info.Conn] = true
+			connsMu.Unlock()
+		},
+		TLSHandshakeStart:    func() { t.start(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.done(&t.tlsStart, &tlsNanos) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.start(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.done(&t.wroteRequest, &firstByteNanos) },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// wireBytes -- set by -wirebytes to count the bytes each phase sends and receives on its connections
+var wireBytes bool
+
+// Bytes written to and read from the phase's connections, headers and TLS framing included, and the requests
+// they carried
+var wireSent, wireReceived, wireRequests int64
+
+// wireConn -- a connection that adds the bytes passing through it to wireSent and wireReceived
+type wireConn struct {
+	net.Conn
+}
+
+func (c wireConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&wireReceived, int64(n))
+	return n, err
+}
+
+func (c wireConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&wireSent, int64(n))
+	return n, err
+}
+
+// wireStats -- what a phase actually transferred, next to the object bytes its speed is based on
+type wireStats struct {
+	Sent     int64   `json:"sent"`
+	Received int64   `json:"received"`
+	Speed    string  `json:"speed"`
+	RawSpeed uint64  `json:"rawSpeed"`
+	Ratio    float64 `json:"logicalRatio,omitempty"`
+	// Averages per request, against the object bytes each one moved
+	RequestSize  float64 `json:"avgRequestBytes"`
+	ResponseSize float64 `json:"avgResponseBytes"`
+	Nominal      float64 `json:"nominalBytes"`
+}
+
+func (w wireStats) String() string {
+	msg := fmt.Sprintf(", wire = %sB/sec (%sB sent, %sB received)",
+		w.Speed, bytefmt.ByteSize(uint64(w.Sent)), bytefmt.ByteSize(uint64(w.Received)))
+	if w.Ratio > 0 {
+		msg += fmt.Sprintf(", %.2fx logical/wire", w.Ratio)
+	}
+	if w.RequestSize > 0 {
+		msg += fmt.Sprintf(", %.0f/%.0f bytes sent/received per request for %.0f object bytes",
+			w.RequestSize, w.ResponseSize, w.Nominal)
+	}
+	return msg
+}
+
+// setWire -- report the phase's wire throughput in the direction its objects travel, sent for
+// uploads and received otherwise, and how the logical speed compares to it
+func setWire(l *logMessage) {
+	if !wireBytes || l.Time <= 0 {
+		return
+	}
+	sent, received := atomic.LoadInt64(&wireSent), atomic.LoadInt64(&wireReceived)
+	moved := received
+	if strings.HasSuffix(l.Method, http.MethodPut) {
+		moved = sent
+	}
+	w := &wireStats{Sent: sent, Received: received, RawSpeed: uint64(float64(moved) / l.Time)}
+	w.Speed = bytefmt.ByteSize(w.RawSpeed)
+	if w.RawSpeed > 0 {
+		w.Ratio = float64(l.RawSpeed) / float64(w.RawSpeed)
+	}
+	if requests := atomic.LoadInt64(&wireRequests); requests > 0 {
+		// Headers are a large part of small requests, which is why their byte throughput is low
+		w.RequestSize = float64(sent) / float64(requests)
+		w.ResponseSize = float64(received) / float64(requests)
+		w.Nominal = float64(l.RawSpeed) * l.Time / float64(requests)
+	}
+	l.Wire = w
+}
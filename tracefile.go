@@ -0,0 +1,162 @@
+// tracefile.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tracePath -- set by -trace to write a record of every request to this file
+var tracePath string
+
+// traceRecord -- one request, from sending it to closing its response body
+type traceRecord struct {
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	Key      string    `json:"key"`
+	Status   int       `json:"status"`
+	Latency  float64   `json:"latencyMs"`
+	Sent     int64     `json:"bytesSent"`
+	Received int64     `json:"bytesReceived"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// traceRecordsBuffered -- how many records can wait for the writer before requests wait for it instead
+const traceRecordsBuffered = 1 << 16
+
+var traceRecords chan traceRecord
+var traceDone sync.WaitGroup
+
+// traceMu -- held to queue a record, and exclusively to close traceRecords
+var traceMu sync.RWMutex
+
+// openTrace -- create the -trace file and start the writer, NDJSON for a .ndjson or .jsonl name and CSV otherwise
+func openTrace() error {
+	file, err := os.Create(tracePath)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriterSize(file, 1<<20)
+	write := csvTraceWriter(buffered)
+	if strings.HasSuffix(tracePath, ".ndjson") || strings.HasSuffix(tracePath, ".jsonl") {
+		write = jsonTraceWriter(buffered)
+	}
+	records := make(chan traceRecord, traceRecordsBuffered)
+	traceRecords = records
+	traceDone.Add(1)
+	go func() {
+		defer traceDone.Done()
+		for r := range records {
+			write(r)
+		}
+		buffered.Flush()
+		file.Close()
+	}()
+	return nil
+}
+
+// closeTrace -- write out the records still queued and close the -trace file
+func closeTrace() {
+	traceMu.Lock()
+	if traceRecords == nil {
+		traceMu.Unlock()
+		return
+	}
+	close(traceRecords)
+	traceRecords = nil
+	traceMu.Unlock()
+	traceDone.Wait()
+}
+
+// queueTrace -- hand a record to the writer, dropping it once the trace is closed, as requests may still be finishing
+// when a fatal error closes it
+func queueTrace(r traceRecord) {
+	traceMu.RLock()
+	if traceRecords != nil {
+		traceRecords <- r
+	}
+	traceMu.RUnlock()
+}
+
+func csvTraceWriter(w io.Writer) func(traceRecord) {
+	out := csv.NewWriter(w)
+	out.Write([]string{"time", "method", "key", "status", "latencyMs", "bytesSent", "bytesReceived", "error"})
+	return func(r traceRecord) {
+		out.Write([]string{
+			r.Time.Format(time.RFC3339Nano),
+			r.Method,
+			r.Key,
+			strconv.Itoa(r.Status),
+			strconv.FormatFloat(r.Latency, 'f', 3, 64),
+			strconv.FormatInt(r.Sent, 10),
+			strconv.FormatInt(r.Received, 10),
+			r.Error,
+		})
+		// Only reaches the file when the bufio.Writer underneath fills or is flushed
+		out.Flush()
+	}
+}
+
+func jsonTraceWriter(w io.Writer) func(traceRecord) {
+	enc := json.NewEncoder(w)
+	return func(r traceRecord) {
+		enc.Encode(&r)
+	}
+}
+
+// traceTransport -- a RoundTripper that queues a traceRecord for each request once its body is closed
+type traceTransport struct {
+	next http.RoundTripper
+}
+
+func (t traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := traceRecord{Time: time.Now(), Method: req.Method, Key: req.URL.Path}
+	if req.ContentLength > 0 {
+		r.Sent = req.ContentLength
+	}
+	if req.URL.RawQuery != "" {
+		r.Key += "?" + req.URL.RawQuery
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		r.Error = err.Error()
+		r.Latency = float64(time.Since(r.Time)) / float64(time.Millisecond)
+		queueTrace(r)
+		return resp, err
+	}
+	r.Status = resp.StatusCode
+	resp.Body = &tracedBody{ReadCloser: resp.Body, record: r}
+	return resp, nil
+}
+
+// tracedBody -- a response body that finishes its request's record when closed
+type tracedBody struct {
+	io.ReadCloser
+	record traceRecord
+	once   sync.Once
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.record.Received += int64(n)
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.record.Latency = float64(time.Since(b.record.Time)) / float64(time.Millisecond)
+		queueTrace(b.record)
+	})
+	return err
+}
@@ -0,0 +1,57 @@
+// tracefile_test.go
+// Copyright (c) 2019 MinIO, Inc.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestFatalWritesTrace -- a fatal exit writes out the -trace records still queued
+func TestFatalWritesTrace(t *testing.T) {
+	if path := os.Getenv("S3_BENCHMARK_FATAL_TRACE"); path != "" {
+		// The child process, which exits in fatal
+		tracePath = path
+		if err := openTrace(); err != nil {
+			t.Fatalf("opening trace: %v", err)
+		}
+		queueTrace(traceRecord{Method: "GET", Key: "/fatal-test/Object-1", Status: 403})
+		fatal("stopping")
+	}
+	dir, err := ioutil.TempDir("", "s3-benchmark")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "requests.csv")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalWritesTrace$")
+	cmd.Env = append(os.Environ(), "S3_BENCHMARK_FATAL_TRACE="+path)
+	if err := cmd.Run(); err == nil {
+		t.Fatal("the fatal exit returned success")
+	}
+	data, _ := ioutil.ReadFile(path)
+	if !bytes.Contains(data, []byte("/fatal-test/Object-1")) {
+		t.Errorf("trace written before a fatal exit is missing the queued record:\n%s", data)
+	}
+}
+
+// TestQueueAfterCloseTrace -- a request finishing after the trace is closed is dropped rather than panicking
+func TestQueueAfterCloseTrace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3-benchmark")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tracePath = filepath.Join(dir, "requests.csv")
+	if err := openTrace(); err != nil {
+		t.Fatalf("opening trace: %v", err)
+	}
+	closeTrace()
+	queueTrace(traceRecord{Method: "GET", Key: "/late"})
+	closeTrace()
+}